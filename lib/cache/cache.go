@@ -10,8 +10,8 @@ import (
 type Cache interface {
 	SetStak(carName string, accNum string, accAlias string, value kion.STAK) error
 	GetStak(carName string, accNum string, accAlias string) (kion.STAK, bool, error)
-	SetSession(value kion.Session) error
-	GetSession() (kion.Session, bool, error)
+	SetSession(host string, idmsID uint, un string, value kion.Session) error
+	GetSession(host string, idmsID uint, un string) (kion.Session, bool, error)
 	SetPassword(host string, idmsID uint, un string, pw string) error
 	GetPassword(host string, idmsID uint, un string) (string, bool, error)
 	FlushCache() error
@@ -31,7 +31,7 @@ type RealCache struct {
 // CacheData is a nested structure for storing kion-cli data.
 type CacheData struct {
 	STAK     map[string]kion.STAK
-	SESSION  kion.Session
+	SESSIONS map[string]kion.Session
 	PASSWORD map[string]string
 }
 
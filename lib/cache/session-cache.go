@@ -2,14 +2,21 @@ package cache
 
 import (
 	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/99designs/keyring"
 	"github.com/kionsoftware/kion-cli/lib/kion"
 )
 
 // SetSession is a common func for all Cache implementations and stores a
-// Session in the cache.
-func setSession(k keyring.Keyring, session kion.Session) error {
+// Session in the cache keyed by host and identity (or removes it if the
+// session is empty).
+func setSession(k keyring.Keyring, host string, idmsID uint, un string, session kion.Session) error {
+	// set the key based on what was passed
+	key := fmt.Sprintf("%s-%d-%s", host, idmsID, un)
+
 	// pull our stak cache
 	cacheName := "Kion-CLI Cache"
 	cache, err := k.Get(cacheName)
@@ -26,8 +33,19 @@ func setSession(k keyring.Keyring, session kion.Session) error {
 		}
 	}
 
-	// store the session
-	cacheData.SESSION = session
+	// initialize the map if it is still nil
+	if cacheData.SESSIONS == nil {
+		cacheData.SESSIONS = make(map[string]kion.Session)
+	}
+
+	if session != (kion.Session{}) {
+		// create/update our entry, tagging it with the host it belongs to
+		session.Host = host
+		cacheData.SESSIONS[key] = session
+	} else {
+		// delete the entry
+		delete(cacheData.SESSIONS, key)
+	}
 
 	// marshal the stack cache to json
 	data, err := json.Marshal(cacheData)
@@ -54,8 +72,13 @@ func setSession(k keyring.Keyring, session kion.Session) error {
 }
 
 // GetSession is a common func for all Cache implementations and retrieves a
-// Session in the cache.
-func getSession(k keyring.Keyring) (kion.Session, bool, error) {
+// Session from the cache. If no username is passed and there is no exact
+// match, any session for the host is returned so a previously prompted
+// identity can be reused.
+func getSession(k keyring.Keyring, host string, idmsID uint, un string) (kion.Session, bool, error) {
+	// set the key based on what was passed
+	key := fmt.Sprintf("%s-%d-%s", host, idmsID, un)
+
 	// pull our stak cache
 	cache, err := k.Get("Kion-CLI Cache")
 	if err != nil {
@@ -74,13 +97,28 @@ func getSession(k keyring.Keyring) (kion.Session, bool, error) {
 		}
 	}
 
-	// return the stak if found
-	session := cacheData.SESSION
-	if session != (kion.Session{}) {
+	// return the session if found
+	session, found := cacheData.SESSIONS[key]
+	if found {
 		return session, true, nil
 	}
 
-	// return empty stak if not found
+	// fallback to any session for the host, sorted for a stable choice
+	if un == "" {
+		keys := make([]string, 0, len(cacheData.SESSIONS))
+		for k := range cacheData.SESSIONS {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			s := cacheData.SESSIONS[k]
+			if strings.EqualFold(s.Host, host) && (idmsID == 0 || s.IDMSID == idmsID) {
+				return s, true, nil
+			}
+		}
+	}
+
+	// return empty session if not found
 	return kion.Session{}, false, nil
 }
 
@@ -92,14 +130,14 @@ func getSession(k keyring.Keyring) (kion.Session, bool, error) {
 
 // SetSession implements the Cache interface for RealCache and wraps a common
 // function for storing session data.
-func (c *RealCache) SetSession(session kion.Session) error {
-	return setSession(c.keyring, session)
+func (c *RealCache) SetSession(host string, idmsID uint, un string, session kion.Session) error {
+	return setSession(c.keyring, host, idmsID, un, session)
 }
 
 // GetSession implements the Cache interface for RealCache and wraps a common
 // function for retrieving session data.
-func (c *RealCache) GetSession() (kion.Session, bool, error) {
-	return getSession(c.keyring)
+func (c *RealCache) GetSession(host string, idmsID uint, un string) (kion.Session, bool, error) {
+	return getSession(c.keyring, host, idmsID, un)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -109,11 +147,11 @@ func (c *RealCache) GetSession() (kion.Session, bool, error) {
 ////////////////////////////////////////////////////////////////////////////////
 
 // SetSession implements the Cache interface for NullCache and does nothing.
-func (c *NullCache) SetSession(session kion.Session) error {
+func (c *NullCache) SetSession(host string, idmsID uint, un string, session kion.Session) error {
 	return nil
 }
 
 // GetSession implements the Cache interface for NullCache and returns an empty session, false, and a nil error.
-func (c *NullCache) GetSession() (kion.Session, bool, error) {
+func (c *NullCache) GetSession(host string, idmsID uint, un string) (kion.Session, bool, error) {
 	return kion.Session{}, false, nil
 }
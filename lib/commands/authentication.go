@@ -1,7 +1,9 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
@@ -12,6 +14,73 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
+// sessionTimeFormat is the format Kion uses for session token expirations.
+const sessionTimeFormat = "2006-01-02T15:04:05-0700"
+
+// samlSessionDuration is how long a SAML session is cached for, tokens are
+// valid for 10 minutes.
+const samlSessionDuration = 570 * time.Second
+
+// refreshFromCookies looks through the cookies set during the SAML token
+// exchange for a refresh token. Kion sets the refresh token as an http only
+// cookie for browser sessions, so we look for it by name and only return it
+// if an expiration can be determined.
+func refreshFromCookies(cookies []*http.Cookie) (string, time.Time, bool) {
+	for _, cookie := range cookies {
+		if !strings.Contains(strings.ToLower(cookie.Name), "refresh") || cookie.Value == "" {
+			continue
+		}
+		switch {
+		case !cookie.Expires.IsZero():
+			return cookie.Value, cookie.Expires, true
+		case cookie.MaxAge > 0:
+			return cookie.Value, time.Now().Add(time.Duration(cookie.MaxAge) * time.Second), true
+		}
+	}
+	return "", time.Time{}, false
+}
+
+// sessionExpiries returns when the access and refresh tokens of a session
+// expire, zero if unknown or absent.
+func sessionExpiries(session kion.Session) (time.Time, time.Time) {
+	access, _ := time.Parse(sessionTimeFormat, session.Access.Expiry)
+	var refresh time.Time
+	if session.Refresh.Token != "" {
+		refresh, _ = time.Parse(sessionTimeFormat, session.Refresh.Expiry)
+	}
+	return access, refresh
+}
+
+// authRefresh exchanges the refresh token of an expired session for a new
+// session, stores the session data, and sets the context token.
+func (c *Cmd) authRefresh(cCtx *cli.Context, session kion.Session) error {
+	newSession, err := c.client.RefreshSession(cCtx.Context, session.Refresh.Token)
+	if err != nil {
+		return err
+	}
+
+	// carry over the identity and keep the current refresh token if Kion did
+	// not issue a new one
+	newSession.IDMSID = session.IDMSID
+	newSession.UserName = session.UserName
+	if newSession.Refresh.Token == "" {
+		newSession.Refresh = session.Refresh
+	}
+	if newSession.Access.Expiry == "" {
+		newSession.Access.Expiry = time.Now().Add(samlSessionDuration).Format(sessionTimeFormat)
+	}
+	newSession.Probed = time.Now()
+
+	err = c.cache.SetSession(c.config.Kion.URL, newSession.IDMSID, newSession.UserName, newSession)
+	if err != nil {
+		return err
+	}
+
+	// use the session for the rest of the run
+	c.setSession(newSession)
+	return nil
+}
+
 // authUNPW prompts for any missing credentials then auths the users against
 // Kion, stores the session data, and sets the context token.
 func (c *Cmd) authUNPW(cCtx *cli.Context) error {
@@ -22,7 +91,7 @@ func (c *Cmd) authUNPW(cCtx *cli.Context) error {
 
 	// prompt idms if needed
 	if idmsID == 0 {
-		idmss, err := kion.GetIDMSs(c.config.Kion.URL)
+		idmss, err := c.client.GetIDMSs(cCtx.Context)
 		if err != nil {
 			return err
 		}
@@ -64,7 +133,7 @@ func (c *Cmd) authUNPW(cCtx *cli.Context) error {
 	}
 
 	// auth and capture our session
-	session, err := kion.Authenticate(c.config.Kion.URL, idmsID, un, pw)
+	session, err := c.client.Authenticate(cCtx.Context, idmsID, un, pw)
 	if err != nil {
 		// Unfortunately, the remote auth endpoint doesn't provide an easy way
 		// of determining if an auth error was the cause of failure (it returns
@@ -83,7 +152,8 @@ func (c *Cmd) authUNPW(cCtx *cli.Context) error {
 	}
 	session.IDMSID = idmsID
 	session.UserName = un
-	err = c.cache.SetSession(session)
+	session.Probed = time.Now()
+	err = c.cache.SetSession(c.config.Kion.URL, idmsID, un, session)
 	if err != nil {
 		return err
 	}
@@ -94,8 +164,8 @@ func (c *Cmd) authUNPW(cCtx *cli.Context) error {
 		return err
 	}
 
-	// set our token in the config
-	c.config.Kion.APIKey = session.Access.Token
+	// use the session for the rest of the run
+	c.setSession(session)
 	return nil
 }
 
@@ -136,7 +206,7 @@ func (c *Cmd) authSAML(cCtx *cli.Context) error {
 
 	var samlMetadata *samlTypes.EntityDescriptor
 	if strings.HasPrefix(samlMetadataFile, "http") {
-		samlMetadata, err = kion.DownloadSAMLMetadata(samlMetadataFile)
+		samlMetadata, err = c.client.DownloadSAMLMetadata(cCtx.Context, samlMetadataFile)
 		if err != nil {
 			return fmt.Errorf("failed to download SAML metadata: %w", err)
 		}
@@ -151,8 +221,8 @@ func (c *Cmd) authSAML(cCtx *cli.Context) error {
 
 	// we only need to check for existence - the value is irrelevant
 	if cCtx.App.Metadata["useOldSAML"] == true {
-		authData, err = kion.AuthenticateSAMLOld(
-			c.config.Kion.URL,
+		authData, err = c.client.AuthenticateSAMLOld(
+			cCtx.Context,
 			samlMetadata,
 			samlServiceProviderIssuer,
 			c.config.Kion.SamlPrintURL,
@@ -161,8 +231,8 @@ func (c *Cmd) authSAML(cCtx *cli.Context) error {
 			return err
 		}
 	} else {
-		authData, err = kion.AuthenticateSAML(
-			c.config.Kion.URL,
+		authData, err = c.client.AuthenticateSAML(
+			cCtx.Context,
 			samlMetadata,
 			samlServiceProviderIssuer,
 			c.config.Kion.SamlPrintURL,
@@ -173,124 +243,181 @@ func (c *Cmd) authSAML(cCtx *cli.Context) error {
 	}
 
 	// cache the session for 9.5 minutes, tokens are valid for 10 minutes
-	timeFormat := "2006-01-02T15:04:05-0700"
 	session := kion.Session{
 		Access: struct {
 			Expiry string `json:"expiry"`
 			Token  string `json:"token"`
 		}{
 			Token:  authData.AuthToken,
-			Expiry: time.Now().Add(570 * time.Second).Format(timeFormat),
+			Expiry: time.Now().Add(samlSessionDuration).Format(sessionTimeFormat),
 		},
+		Probed: time.Now(),
+	}
+
+	// hold on to the refresh token if Kion handed one back so the session can
+	// be extended without another trip through the IDP
+	if token, expiry, found := refreshFromCookies(authData.Cookies); found {
+		session.Refresh.Token = token
+		session.Refresh.Expiry = expiry.Format(sessionTimeFormat)
 	}
-	err = c.cache.SetSession(session)
+
+	err = c.cache.SetSession(c.config.Kion.URL, 0, "", session)
 	if err != nil {
 		return err
 	}
 
-	// set our token in the config
-	c.config.Kion.APIKey = authData.AuthToken
+	// use the session for the rest of the run
+	c.setSession(session)
+	return nil
+}
+
+// errAPIKeyRejected explains a 401 from Kion when an API key is in use.
+var errAPIKeyRejected = errors.New("the API key was rejected by Kion (401 unauthorized), verify the value passed with --token, KION_API_KEY, or 'api_key' in your configuration file")
+
+// validateAPIKey probes Kion with the configured token and returns a clear
+// error if it has been rejected. An accepted key isn't probed again this run.
+func (c *Cmd) validateAPIKey(cCtx *cli.Context) error {
+	if c.apiKeyProbed == c.config.Kion.APIKey {
+		return nil
+	}
+	valid, err := c.client.ValidateToken(cCtx.Context, c.config.Kion.APIKey)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return errAPIKeyRejected
+	}
+	c.apiKeyProbed = c.config.Kion.APIKey
 	return nil
 }
 
+// ExplainError replaces a 401 from Kion with a clear error when an API key
+// is in use, catching keys revoked after they were probed.
+func (c *Cmd) ExplainError(err error) error {
+	if c.config.Kion.APIKey != "" && kion.IsStatus(err, 401) {
+		return fmt.Errorf("%w: %v", errAPIKeyRejected, err)
+	}
+	return err
+}
+
 // setAuthToken sets the token to be used for querying the Kion API. If not
 // passed to the tool as an argument, set in the env, or present in the
 // configuration dotfile it will prompt the users to authenticate. Auth methods
 // are prioritized as follows: api/bearer token -> username/password -> saml.
 // If flags are set for multiple methods the highest priority method will be
-// used.
+// used. API keys are probed once per run and cached sessions at most every
+// kion.TokenProbeTTL, so revoked or stale tokens are caught before they fail a
+// later request.
 func (c *Cmd) setAuthToken(cCtx *cli.Context) error {
-	if c.config.Kion.APIKey == "" {
-		// if we still have an active session use it
-		session, found, err := c.cache.GetSession()
-		if err != nil {
-			return err
+	// catch a mistyped or revoked api key before it's used
+	if c.config.Kion.APIKey != "" {
+		return c.validateAPIKey(cCtx)
+	}
+
+	// reuse the session from earlier in this run, renewing it if it expired
+	if c.session.Access.Token != "" {
+		now := time.Now()
+		access, refresh := sessionExpiries(c.session)
+		if access.After(now) {
+			c.setSession(c.session)
+			return nil
 		}
-		if found && session.Access.Expiry != "" {
-			timeFormat := "2006-01-02T15:04:05-0700"
-			now := time.Now()
-			expiration, err := time.Parse(timeFormat, session.Access.Expiry)
-			if err != nil {
-				return err
-			}
-			if expiration.After(now) {
-				// TODO: test token is good with an endpoint that is accessible to all
-				// user permission levels, if you get a 401 then assume token is bad
-				// due to caching a cred when a users password expired, and flush the
-				// cache instead...
-				c.config.Kion.APIKey = session.Access.Token
+		if refresh.After(now) {
+			if err := c.authRefresh(cCtx, c.session); err == nil {
 				return nil
 			}
-
-			// TODO: uncomment when / if the application supports refresh tokens
-
-			// // see if we can use the refresh token
-			// refreshExp, err := time.Parse(timeFormat, session.Refresh.Expiry)
-			// if err != nil {
-			// 	return err
-			// }
-
-			// if refreshExp.After(now) {
-			// 	un := session.UserName
-			// 	idmsId := session.IDMSID
-			// 	session, err = kion.Authenticate(c.config.Kion.Url, idmsId, un, session.Refresh.Token)
-			// 	if err != nil {
-			// 		return err
-			// 	}
-			// 	session.UserName = un
-			// 	session.IDMSID = idmsId
-			// 	err = c.cache.SetSession(session)
-			// 	if err != nil {
-			// 		return err
-			// 	}
-
-			//  c.config.Kion.ApiKey = session.Access.Token
-			// 	return nil
-			// }
-		}
-
-		// check un / pw were set via flags and infer auth method
-		if c.config.Kion.Username != "" || c.config.Kion.Password != "" {
-			err := c.authUNPW(cCtx)
-			return err
-		}
-
-		// check if saml auth flags set and auth with saml if so
-		if c.config.Kion.SamlMetadataFile != "" && c.config.Kion.SamlIssuer != "" {
-			err := c.authSAML(cCtx)
-			return err
 		}
+	}
 
-		// if no token or session found, prompt for desired auth method
-		methods := []string{
-			"API Key",
-			"Password",
-			"SAML",
-		}
-		authMethod, err := helper.PromptSelect("How would you like to authenticate?", "Choose your preferred authentication method.", methods)
+	// if we still have an active session for this Kion and identity use it
+	session, found, err := c.cache.GetSession(c.config.Kion.URL, cCtx.Uint("idms"), c.config.Kion.Username)
+	if err != nil {
+		return err
+	}
+	if found && session.Access.Expiry != "" {
+		now := time.Now()
+		expiration, err := time.Parse(sessionTimeFormat, session.Access.Expiry)
 		if err != nil {
 			return err
 		}
+		if expiration.After(now) {
+			// trust a session kion accepted recently
+			if now.Sub(session.Probed) < kion.TokenProbeTTL {
+				c.setSession(session)
+				return nil
+			}
 
-		// handle chosen auth method
-		switch authMethod {
-		case "API Key":
-			apiKey, err := helper.PromptPassword("API Key:")
+			// a 401 means the token was revoked or the users password changed
+			// since it was cached, flush it and authenticate again
+			valid, err := c.client.ValidateToken(cCtx.Context, session.Access.Token)
 			if err != nil {
 				return err
 			}
-			c.config.Kion.APIKey = apiKey
-		case "Password":
-			err := c.authUNPW(cCtx)
-			if err != nil {
-				return err
+			if valid {
+				session.Probed = now
+				err = c.cache.SetSession(session.Host, session.IDMSID, session.UserName, session)
+				if err != nil {
+					return err
+				}
+				c.setSession(session)
+				return nil
 			}
-		case "SAML":
-			err := c.authSAML(cCtx)
+			err = c.cache.SetSession(session.Host, session.IDMSID, session.UserName, kion.Session{})
 			if err != nil {
 				return err
 			}
+		} else if session.Refresh.Token != "" && session.Refresh.Expiry != "" {
+			// see if we can use the refresh token, if the exchange fails fall
+			// through to a full authentication
+			refreshExp, err := time.Parse(sessionTimeFormat, session.Refresh.Expiry)
+			if err == nil && refreshExp.After(now) {
+				if err := c.authRefresh(cCtx, session); err == nil {
+					return nil
+				}
+			}
+		}
+	}
+
+	// don't prompt while another process owns the terminal
+	if c.noPrompt && (c.config.Kion.Username == "" || c.config.Kion.Password == "") {
+		return errors.New("the Kion session has expired, run 'kion login' to authenticate again")
+	}
+
+	// check un / pw were set via flags and infer auth method
+	if c.config.Kion.Username != "" || c.config.Kion.Password != "" {
+		return c.authUNPW(cCtx)
+	}
+
+	// check if saml auth flags set and auth with saml if so
+	if c.config.Kion.SamlMetadataFile != "" && c.config.Kion.SamlIssuer != "" {
+		return c.authSAML(cCtx)
+	}
+
+	// if no token or session found, prompt for desired auth method
+	methods := []string{
+		"API Key",
+		"Password",
+		"SAML",
+	}
+	authMethod, err := helper.PromptSelect("How would you like to authenticate?", "Choose your preferred authentication method.", methods)
+	if err != nil {
+		return err
+	}
+
+	// handle chosen auth method
+	switch authMethod {
+	case "API Key":
+		apiKey, err := helper.PromptPassword("API Key:")
+		if err != nil {
+			return err
 		}
+		c.setToken(apiKey)
+		return c.validateAPIKey(cCtx)
+	case "Password":
+		return c.authUNPW(cCtx)
+	case "SAML":
+		return c.authSAML(cCtx)
 	}
+
 	return nil
 }
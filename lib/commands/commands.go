@@ -3,9 +3,12 @@ package commands
 
 import (
 	"fmt"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/99designs/keyring"
+	"github.com/fatih/color"
 	"github.com/hashicorp/go-version"
 	"github.com/kionsoftware/kion-cli/lib/cache"
 	"github.com/kionsoftware/kion-cli/lib/helper"
@@ -21,11 +24,32 @@ import (
 //                                                                            //
 ////////////////////////////////////////////////////////////////////////////////
 
-// Cmd is the main command object for the Kion CLI. It makes the configuration
-// and cache available to all command actions.
+// defaultTimeout is how long to wait on any single request to Kion when no
+// timeout has been configured.
+const defaultTimeout = 30 * time.Second
+
+// Cmd is the main command object for the Kion CLI. It makes the configuration,
+// cache, and Kion API client available to all command actions.
 type Cmd struct {
 	config *structs.Configuration
 	cache  cache.Cache
+	client *kion.Client
+
+	// session is the Kion session authenticated during this run, kept so long
+	// running commands can renew it once it expires
+	session kion.Session
+
+	// apiKeyProbed is the API key Kion accepted during this run, so it's only
+	// probed once
+	apiKeyProbed string
+
+	// noPrompt is set while another process owns the terminal, authentication
+	// that would require user input fails instead
+	noPrompt bool
+
+	// authMu serializes authentication and cache access between a command and
+	// its background credential refreshes
+	authMu sync.Mutex
 }
 
 // NewCommands stands up a new instance of commands with the provided
@@ -42,6 +66,20 @@ func NewCommands(cfg *structs.Configuration) *Cmd {
 //                                                                            //
 ////////////////////////////////////////////////////////////////////////////////
 
+// setToken sets the API key used to authenticate with Kion for the remainder
+// of the run.
+func (c *Cmd) setToken(token string) {
+	c.config.Kion.APIKey = token
+	c.client.Token = token
+}
+
+// setSession authenticates with Kion using the given session until it
+// expires.
+func (c *Cmd) setSession(session kion.Session) {
+	c.session = session
+	c.client.Token = session.Access.Token
+}
+
 // getSecondArgument returns the second positional argument passed to the cli.
 func getSecondArgument(cCtx *cli.Context) string {
 	if cCtx.Args().Len() > 0 {
@@ -72,6 +110,12 @@ func (c *Cmd) setEndpoint() error {
 	return nil
 }
 
+// shell returns the shell to start sub-shells in and print exports for, as
+// configured or else detected.
+func (c *Cmd) shell() (helper.Shell, error) {
+	return helper.DetectShell(c.config.Kion.Shell)
+}
+
 // getActionAndBuffer determines the action based on the passed flags and sets
 // a buffer for the associated action used to determine the cache validity.
 func getActionAndBuffer(cCtx *cli.Context) (string, time.Duration) {
@@ -80,10 +124,10 @@ func getActionAndBuffer(cCtx *cli.Context) (string, time.Duration) {
 
 	var action string
 	var buffer time.Duration
-	if cCtx.Bool("credential-process") {
+	if cCtx.Bool("credential-process") || cCtx.String("format") == "credential-process" {
 		action = "credential-process"
 		buffer = 5
-	} else if cCtx.Bool("print") || cmdUsed == "setenv" {
+	} else if cCtx.Bool("print") || cCtx.String("format") != "" || cmdUsed == "setenv" {
 		action = "print"
 		buffer = 300
 	} else if cCtx.Bool("save") || cmdUsed == "savecreds" {
@@ -107,7 +151,7 @@ func (c *Cmd) authStakCache(cCtx *cli.Context, carName string, accNum string, ac
 	}
 
 	// generate short term tokens
-	stak, err := kion.GetSTAK(c.config.Kion.URL, c.config.Kion.APIKey, carName, accNum, accAlias)
+	stak, err := c.client.GetSTAK(cCtx.Context, carName, accNum, accAlias)
 	if err != nil {
 		return kion.STAK{}, err
 	}
@@ -184,6 +228,12 @@ func (c *Cmd) handleProfile(profileName string, cCtx *cli.Context) error {
 		var disableCacheFlagged bool
 		var debugFlagged bool
 		var quietFlagged bool
+		var timeoutFlagged bool
+		var timeout time.Duration
+		var retryAttemptsFlagged bool
+		var retryAttempts int
+		var insecureFlagged bool
+		var saveRegionFlagged bool
 
 		setGlobalFlags := cCtx.FlagNames()
 		for _, flag := range setGlobalFlags {
@@ -202,6 +252,8 @@ func (c *Cmd) handleProfile(profileName string, cCtx *cli.Context) error {
 				setStrings["saml-sp-issuer"] = c.config.Kion.SamlIssuer
 			case "token":
 				setStrings["token"] = c.config.Kion.APIKey
+			case "ca-bundle", "client-cert", "client-key", "proxy-url", "subshell-credentials", "shell", "aws-profile-template":
+				setStrings[flag] = cCtx.String(flag)
 			// non-string flags
 			case "disable-cache":
 				disableCacheFlagged = true
@@ -209,6 +261,16 @@ func (c *Cmd) handleProfile(profileName string, cCtx *cli.Context) error {
 				debugFlagged = true
 			case "quiet":
 				quietFlagged = true
+			case "timeout":
+				timeoutFlagged = true
+				timeout = c.config.Kion.Timeout
+			case "retry-attempts":
+				retryAttemptsFlagged = true
+				retryAttempts = c.config.Kion.RetryAttempts
+			case "insecure-skip-verify":
+				insecureFlagged = true
+			case "save-region":
+				saveRegionFlagged = true
 			}
 		}
 
@@ -217,6 +279,10 @@ func (c *Cmd) handleProfile(profileName string, cCtx *cli.Context) error {
 		if found {
 			c.config.Kion = profile.Kion
 			c.config.Favorites = profile.Favorites
+
+			// profile tls paths are relative to the config file like top level ones
+			configPath, _ := cCtx.App.Metadata["configPath"].(string)
+			helper.ResolveTLSPaths(&c.config.Kion, configPath)
 		} else {
 			return fmt.Errorf("profile not found: %s", profileName)
 		}
@@ -239,6 +305,18 @@ func (c *Cmd) handleProfile(profileName string, cCtx *cli.Context) error {
 		if quietFlagged {
 			c.config.Kion.QuietMode = true
 		}
+		if timeoutFlagged {
+			c.config.Kion.Timeout = timeout
+		}
+		if retryAttemptsFlagged {
+			c.config.Kion.RetryAttempts = retryAttempts
+		}
+		if insecureFlagged {
+			c.config.Kion.InsecureSkipTLS = true
+		}
+		if saveRegionFlagged {
+			c.config.Kion.SaveRegion = true
+		}
 	}
 	return nil
 }
@@ -265,14 +343,73 @@ func (c *Cmd) BeforeCommands(cCtx *cli.Context) error {
 		return err
 	}
 
+	// catch an unsupported shell before any keys are minted for it
+	if _, err := c.shell(); err != nil {
+		return err
+	}
+
+	// status only reads the environment and cache, skip talking to Kion so it
+	// stays fast enough to run from a shell prompt
+	if args[0] == "status" {
+		return nil
+	}
+
+	// pruning saved credentials only touches local files
+	if args[0] == "util" && len(args) > 1 && args[1] == "prune-aws-creds" {
+		return nil
+	}
+
 	// grab the Kion url if not already set
 	err = c.setEndpoint()
 	if err != nil {
 		return err
 	}
 
+	// stand up the client used for all calls to Kion
+	c.client = kion.NewClient(c.config.Kion.URL, c.config.Kion.APIKey)
+	c.client.UserAgent = kion.DefaultUserAgent + "/" + cCtx.App.Version
+	c.client.HTTPClient.Timeout = c.config.Kion.Timeout
+	if c.client.HTTPClient.Timeout <= 0 {
+		c.client.HTTPClient.Timeout = defaultTimeout
+	}
+	if c.config.Kion.RetryAttempts > 0 {
+		c.client.Retry.MaxAttempts = c.config.Kion.RetryAttempts
+	}
+	// an explicit 0 turns jitter off, so only unset values fall back
+	if c.config.Kion.RetryJitter != nil {
+		c.client.Retry.Jitter = *c.config.Kion.RetryJitter
+	}
+	if cCtx.IsSet("retry-jitter") {
+		c.client.Retry.Jitter = cCtx.Float64("retry-jitter")
+	}
+	transport, err := kion.NewTransport(kion.TransportOptions{
+		CABundle:           c.config.Kion.CABundle,
+		ClientCert:         c.config.Kion.ClientCert,
+		ClientKey:          c.config.Kion.ClientKey,
+		ProxyURL:           c.config.Kion.ProxyURL,
+		InsecureSkipVerify: c.config.Kion.InsecureSkipTLS,
+	})
+	if err != nil {
+		return err
+	}
+	c.client.HTTPClient.Transport = transport
+
+	// trace all http traffic to stderr when debugging
+	if c.config.Kion.DebugMode {
+		c.client.HTTPClient.Transport = kion.NewTracingTransport(transport, os.Stderr)
+	}
+
+	// make it impossible to miss that tls verification is off, stderr keeps
+	// credential process output clean
+	if c.config.Kion.InsecureSkipTLS {
+		fmt.Fprintln(os.Stderr, color.New(color.FgRed, color.Bold).Sprint(
+			"WARNING: TLS certificate verification is disabled (insecure_skip_verify). "+
+				"Credentials sent to Kion and your identity provider can be intercepted.",
+		))
+	}
+
 	// gather the targeted Kion version
-	kionVer, err := kion.GetVersion(c.config.Kion.URL)
+	kionVer, err := c.client.GetVersion(cCtx.Context)
 	if err != nil {
 		return err
 	}
@@ -280,6 +417,7 @@ func (c *Cmd) BeforeCommands(cCtx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	cCtx.App.Metadata["kionVersion"] = kionVer
 
 	// api/v3/me/cloud-access-role fix constraints
 	v3mecarC1, _ := version.NewConstraint(">=3.6.29, < 3.7.0")
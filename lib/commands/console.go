@@ -28,26 +28,26 @@ func (c *Cmd) FedConsole(cCtx *cli.Context) error {
 	if carName != "" && (accNum != "" || accountAlias != "") {
 		// fetch the car directly using account number or alias and car name
 		if accNum != "" {
-			car, err = kion.GetCARByNameAndAccount(c.config.Kion.URL, c.config.Kion.APIKey, carName, accNum)
+			car, err = c.client.GetCARByNameAndAccount(cCtx.Context, carName, accNum)
 			if err != nil {
 				return fmt.Errorf("failed to get CAR for account %s and CAR %s: %v", accNum, carName, err)
 			}
 		} else {
-			car, err = kion.GetCARByNameAndAlias(c.config.Kion.URL, c.config.Kion.APIKey, carName, accountAlias)
+			car, err = c.client.GetCARByNameAndAlias(cCtx.Context, carName, accountAlias)
 			if err != nil {
 				return fmt.Errorf("failed to get CAR for alias %s and CAR %s: %v", accountAlias, carName, err)
 			}
 		}
 	} else {
 		// walk user through the prompt workflow to select a car
-		err = helper.CARSelector(cCtx, &car)
+		err = helper.CARSelector(cCtx, c.client, &car)
 		if err != nil {
 			return err
 		}
 	}
 
 	// grab the csp federation url
-	url, err := kion.GetFederationURL(c.config.Kion.URL, c.config.Kion.APIKey, car)
+	url, err := c.client.GetFederationURL(cCtx.Context, car)
 	if err != nil {
 		return err
 	}
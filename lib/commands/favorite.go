@@ -1,11 +1,14 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"sort"
 	"time"
 
+	"github.com/fatih/color"
 	"github.com/kionsoftware/kion-cli/lib/helper"
 	"github.com/kionsoftware/kion-cli/lib/kion"
 	"github.com/kionsoftware/kion-cli/lib/structs"
@@ -24,7 +27,7 @@ func (c *Cmd) getFavorites(cCtx *cli.Context) ([]structs.Favorite, error) {
 		if err != nil {
 			return apiFavorites, err
 		}
-		apiFavorites, _, err = kion.GetAPIFavorites(c.config.Kion.URL, c.config.Kion.APIKey)
+		apiFavorites, err = c.client.GetAPIFavorites(cCtx.Context)
 		if err != nil {
 			fmt.Printf("Error retrieving favorites from API: %v\n", err)
 			return apiFavorites, err
@@ -121,16 +124,16 @@ func (c *Cmd) Favorites(cCtx *cli.Context) error {
 		}
 
 		// attempt to find an exact match then fallback to the first match
-		car, err := kion.GetCARByNameAndAccount(c.config.Kion.URL, c.config.Kion.APIKey, favorite.CAR, favorite.Account)
+		car, err := c.client.GetCARByNameAndAccount(cCtx.Context, favorite.CAR, favorite.Account)
 		if err != nil {
-			car, err = kion.GetCARByName(c.config.Kion.URL, c.config.Kion.APIKey, favorite.CAR)
+			car, err = c.client.GetCARByName(cCtx.Context, favorite.CAR)
 			if err != nil {
 				return err
 			}
 			car.AccountNumber = favorite.Account
 		}
 
-		url, err := kion.GetFederationURL(c.config.Kion.URL, c.config.Kion.APIKey, car)
+		url, err := c.client.GetFederationURL(cCtx.Context, car)
 		if err != nil {
 			return err
 		}
@@ -170,11 +173,535 @@ func (c *Cmd) Favorites(cCtx *cli.Context) error {
 			// NOTE: Do not use os.Stderr here else credentials can be written to logs
 			return helper.PrintCredentialProcess(os.Stdout, stak)
 		case "print":
-			return helper.PrintSTAK(os.Stdout, stak, favorite.Region)
+			shell, err := c.shell()
+			if err != nil {
+				return err
+			}
+			details := helper.STAKDetails{
+				STAK:   stak,
+				CAR:    kion.CAR{Name: favorite.CAR, AccountNumber: favorite.Account},
+				Region: favorite.Region,
+				Shell:  shell,
+			}
+			return helper.PrintSTAKFormat(os.Stdout, cCtx.String("format"), details)
 		case "subshell":
-			return helper.CreateSubShell(favorite.Account, favorite.Name, favorite.CAR, stak, favorite.Region)
+			car := kion.CAR{
+				Name:          favorite.CAR,
+				AccountNumber: favorite.Account,
+				AccountName:   favorite.Name,
+			}
+			return c.subShell(cCtx, car, favorite.Name, stak, favorite.Region)
 		default:
 			return nil
 		}
 	}
 }
+
+// loadProfileFavorites loads the configuration file and returns it with the
+// favorites of the named profile, or the top level favorites if no profile is
+// named.
+func loadProfileFavorites(configPath string, profileName string) (structs.Configuration, []structs.Favorite, error) {
+	var config structs.Configuration
+	err := helper.LoadConfig(configPath, &config)
+	if err != nil {
+		return config, nil, err
+	}
+	favorites, err := helper.ProfileFavorites(config, profileName)
+	return config, favorites, err
+}
+
+// editProfileFavorites applies an edit to the favorites stored in the
+// configuration file for the named profile, or the top level favorites if no
+// profile is named, and saves only those favorites back. Editing the active
+// profile updates the favorites in use for the rest of the run too.
+func (c *Cmd) editProfileFavorites(cCtx *cli.Context, profileName string, edit func([]structs.Favorite) ([]structs.Favorite, error)) error {
+	configPath := cCtx.App.Metadata["configPath"].(string)
+
+	_, favorites, err := loadProfileFavorites(configPath, profileName)
+	if err != nil {
+		return err
+	}
+	favorites, err = edit(slices.Clone(favorites))
+	if err != nil {
+		return err
+	}
+
+	err = helper.SaveFavorites(configPath, profileName, favorites)
+	if err != nil {
+		return err
+	}
+	if profileName == cCtx.String("profile") {
+		c.config.Favorites = favorites
+	}
+	return nil
+}
+
+// editLocalFavorites applies an edit to the favorites stored in the
+// configuration file for the active profile, see editProfileFavorites.
+func (c *Cmd) editLocalFavorites(cCtx *cli.Context, edit func([]structs.Favorite) ([]structs.Favorite, error)) error {
+	return c.editProfileFavorites(cCtx, cCtx.String("profile"), edit)
+}
+
+// requireFavoritesAPI returns an error if the Kion instance has no API for
+// favorites.
+func requireFavoritesAPI(cCtx *cli.Context) error {
+	if !cCtx.App.Metadata["useFavoritesAPI"].(bool) {
+		return errors.New("favorites API is not enabled. This requires Kion version 3.13.5, 3.14.1 or higher")
+	}
+	return nil
+}
+
+// upstreamFavorites returns the favorites stored in Kion for the
+// authenticated user.
+func (c *Cmd) upstreamFavorites(cCtx *cli.Context) ([]structs.Favorite, error) {
+	err := requireFavoritesAPI(cCtx)
+	if err != nil {
+		return nil, err
+	}
+	err = c.setAuthToken(cCtx)
+	if err != nil {
+		return nil, err
+	}
+	return c.client.GetAPIFavorites(cCtx.Context)
+}
+
+// confirmUpstream asks the user to confirm a change to favorites in Kion,
+// unless --yes was passed.
+func confirmUpstream(cCtx *cli.Context, prompt string) (bool, error) {
+	if cCtx.Bool("yes") {
+		return true, nil
+	}
+	selection, err := helper.PromptSelect(prompt, "", []string{"no", "yes"})
+	if err != nil {
+		return false, err
+	}
+	return selection == "yes", nil
+}
+
+// createUpstream creates a favorite in Kion once confirmed. A favorite in
+// Kion without an alias for the same cloud access role gets the new alias.
+func (c *Cmd) createUpstream(cCtx *cli.Context, favorite structs.Favorite, existing []structs.Favorite) error {
+	prompt := fmt.Sprintf("Add favorite %v (%v %v %v) to Kion (%v)?", favorite.Name, favorite.Account, favorite.CAR, favorite.AccessType, c.config.Kion.URL)
+	unaliased, err := helper.UpstreamAddTarget(existing, favorite)
+	if err != nil {
+		return err
+	}
+	if unaliased != nil {
+		prompt = fmt.Sprintf("Set the alias of the unaliased favorite for %v %v %v in Kion (%v) to %v?", favorite.Account, favorite.CAR, favorite.AccessType, c.config.Kion.URL, favorite.Name)
+	}
+
+	ok, err := confirmUpstream(cCtx, prompt)
+	if !ok || err != nil {
+		fmt.Println("\nAborting, no favorites were changed.")
+		return err
+	}
+
+	err = helper.AddUpstreamFavorite(cCtx.Context, c.client, favorite)
+	if err != nil {
+		return err
+	}
+
+	color.Green("Added favorite %v to Kion", favorite.Name)
+	return nil
+}
+
+// validateFavoriteTarget checks that the authenticated user has the
+// favorite's cloud access role on its account, with the access it needs.
+func (c *Cmd) validateFavoriteTarget(cCtx *cli.Context, favorite structs.Favorite) error {
+	err := c.setAuthToken(cCtx)
+	if err != nil {
+		return err
+	}
+	cars, err := c.client.GetCARS(cCtx.Context, "")
+	if err != nil {
+		return err
+	}
+
+	index := slices.IndexFunc(cars, func(car kion.CAR) bool {
+		return car.AccountNumber == favorite.Account && car.Name == favorite.CAR
+	})
+	if index < 0 {
+		return fmt.Errorf("you have no cloud access role %q on account %v", favorite.CAR, favorite.Account)
+	}
+	car := cars[index]
+	if favorite.AccessType == "web" && !car.WebAccess {
+		return fmt.Errorf("cloud access role %q on account %v does not allow web console access", favorite.CAR, favorite.Account)
+	}
+	if favorite.AccessType != "web" && !car.ShortTermAccessKeys {
+		return fmt.Errorf("cloud access role %q on account %v does not allow short-term access keys", favorite.CAR, favorite.Account)
+	}
+	return nil
+}
+
+// favoriteFields returns the favorite fields passed as flags, keyed by flag
+// name, for helper.SetFavoriteFields.
+func favoriteFields(cCtx *cli.Context) map[string]string {
+	fields := make(map[string]string)
+	for _, name := range helper.FavoriteFieldFlags {
+		if cCtx.IsSet(name) {
+			fields[name] = cCtx.String(name)
+		}
+	}
+	return fields
+}
+
+// AddFavorite saves a new favorite to the configuration file, or to Kion with
+// --upstream. The account and cloud access role are taken from flags, or
+// selected with the same wizard as `kion stak` when not passed.
+func (c *Cmd) AddFavorite(cCtx *cli.Context) error {
+	name := cCtx.Args().First()
+	if name == "" {
+		var err error
+		name, err = helper.PromptInput("Favorite name:")
+		if err != nil {
+			return err
+		}
+	}
+
+	// names must be unique where the favorite is stored
+	upstream := cCtx.Bool("upstream")
+	existing := c.config.Favorites
+	if upstream {
+		var err error
+		existing, err = c.upstreamFavorites(cCtx)
+		if err != nil {
+			return err
+		}
+	}
+	if err := helper.ValidateFavoriteName(name, existing); err != nil {
+		return err
+	}
+
+	favorite := structs.Favorite{Name: name, AccessType: "cli"}
+	helper.SetFavoriteFields(&favorite, favoriteFields(cCtx))
+
+	// fill the account and car from the wizard if not passed
+	if favorite.Account == "" || favorite.CAR == "" {
+		err := c.setAuthToken(cCtx)
+		if err != nil {
+			return err
+		}
+		car := kion.CAR{Name: favorite.CAR, AccountNumber: favorite.Account}
+		err = helper.CARSelector(cCtx, c.client, &car)
+		if err != nil {
+			return err
+		}
+		favorite.Account = car.AccountNumber
+		favorite.CAR = car.Name
+	}
+
+	err := c.validateFavoriteTarget(cCtx, favorite)
+	if err != nil {
+		return err
+	}
+
+	if upstream {
+		return c.createUpstream(cCtx, favorite, existing)
+	}
+
+	err = c.editLocalFavorites(cCtx, func(favorites []structs.Favorite) ([]structs.Favorite, error) {
+		if err := helper.ValidateFavoriteName(name, favorites); err != nil {
+			return nil, err
+		}
+		return append(favorites, favorite), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	color.Green("Added favorite %v (%v %v %v)", favorite.Name, favorite.Account, favorite.CAR, favorite.AccessType)
+	return nil
+}
+
+// RemoveFavorite removes a favorite from the configuration file, or from Kion
+// with --upstream.
+func (c *Cmd) RemoveFavorite(cCtx *cli.Context) error {
+	name := cCtx.Args().First()
+
+	if cCtx.Bool("upstream") {
+		favorites, err := c.upstreamFavorites(cCtx)
+		if err != nil {
+			return err
+		}
+		f, err := helper.FindUpstreamFavorite(favorites, name)
+		if err != nil {
+			return err
+		}
+
+		ok, err := confirmUpstream(cCtx, fmt.Sprintf("Delete favorite %v (%v %v %v) from Kion (%v)?", f.Name, f.Account, f.CAR, f.AccessType, c.config.Kion.URL))
+		if !ok || err != nil {
+			fmt.Println("\nAborting, no favorites were changed.")
+			return err
+		}
+		err = c.client.DeleteFavorite(cCtx.Context, name)
+		if err != nil {
+			return err
+		}
+
+		color.Green("Removed favorite %v from Kion", name)
+		return nil
+	}
+
+	err := c.editLocalFavorites(cCtx, func(favorites []structs.Favorite) ([]structs.Favorite, error) {
+		index := helper.FindFavorite(favorites, name)
+		if index < 0 {
+			return nil, fmt.Errorf("no local favorite named %q", name)
+		}
+		return slices.Delete(favorites, index, index+1), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	color.Green("Removed favorite %v", name)
+	return nil
+}
+
+// RenameFavorite renames a favorite in the configuration file, or in Kion with
+// --upstream.
+func (c *Cmd) RenameFavorite(cCtx *cli.Context) error {
+	name := cCtx.Args().Get(0)
+	newName := cCtx.Args().Get(1)
+
+	if cCtx.Bool("upstream") {
+		return c.renameUpstream(cCtx, name, newName)
+	}
+
+	err := c.editLocalFavorites(cCtx, func(favorites []structs.Favorite) ([]structs.Favorite, error) {
+		index := helper.FindFavorite(favorites, name)
+		if index < 0 {
+			return nil, fmt.Errorf("no local favorite named %q", name)
+		}
+		if err := helper.ValidateFavoriteName(newName, favorites); err != nil {
+			return nil, err
+		}
+		favorites[index].Name = newName
+		return favorites, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	color.Green("Renamed favorite %v to %v", name, newName)
+	return nil
+}
+
+// renameUpstream renames a favorite in Kion once confirmed, see
+// helper.RenameUpstreamFavorite.
+func (c *Cmd) renameUpstream(cCtx *cli.Context, name string, newName string) error {
+	favorites, err := c.upstreamFavorites(cCtx)
+	if err != nil {
+		return err
+	}
+	favorite, err := helper.FindUpstreamFavorite(favorites, name)
+	if err != nil {
+		return err
+	}
+	if err := helper.ValidateFavoriteName(newName, favorites); err != nil {
+		return err
+	}
+
+	ok, err := confirmUpstream(cCtx, fmt.Sprintf("Rename favorite %v to %v in Kion (%v)?", name, newName, c.config.Kion.URL))
+	if !ok || err != nil {
+		fmt.Println("\nAborting, no favorites were changed.")
+		return err
+	}
+
+	err = helper.RenameUpstreamFavorite(cCtx.Context, c.client, favorite, newName)
+	if err != nil {
+		return err
+	}
+
+	color.Green("Renamed favorite %v to %v in Kion", name, newName)
+	return nil
+}
+
+// EditFavorite changes the fields of a favorite in the configuration file
+// that were passed as flags.
+func (c *Cmd) EditFavorite(cCtx *cli.Context) error {
+	name := cCtx.Args().First()
+
+	index := helper.FindFavorite(c.config.Favorites, name)
+	if index < 0 {
+		return fmt.Errorf("no local favorite named %q", name)
+	}
+	favorite := c.config.Favorites[index]
+	helper.SetFavoriteFields(&favorite, favoriteFields(cCtx))
+
+	// only check with Kion when what the favorite points at changed
+	if cCtx.IsSet("account") || cCtx.IsSet("car") || cCtx.IsSet("access-type") {
+		err := c.validateFavoriteTarget(cCtx, favorite)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := c.editLocalFavorites(cCtx, func(favorites []structs.Favorite) ([]structs.Favorite, error) {
+		index := helper.FindFavorite(favorites, name)
+		if index < 0 {
+			return nil, fmt.Errorf("no local favorite named %q", name)
+		}
+		helper.SetFavoriteFields(&favorites[index], favoriteFields(cCtx))
+		return favorites, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	color.Green("Updated favorite %v", name)
+	return nil
+}
+
+// ExportFavorites prints the favorites in use, local and from Kion, as a
+// favorites file that can be shared and imported.
+func (c *Cmd) ExportFavorites(cCtx *cli.Context) error {
+	favorites, err := c.getFavorites(cCtx)
+	if err != nil {
+		return err
+	}
+
+	// local favorites conflicting with Kion are listed with them, keep the first
+	var exported []structs.Favorite
+	for _, f := range favorites {
+		if helper.FindFavorite(exported, f.Name) < 0 {
+			exported = append(exported, f)
+		}
+	}
+	sort.SliceStable(exported, func(i, j int) bool {
+		return exported[i].Name < exported[j].Name
+	})
+
+	data, err := helper.MarshalFavorites(exported, cCtx.String("output"))
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+// ImportFavorites merges favorites from a file or URL into the configuration
+// file, or pushes them to Kion with --upstream. Favorites conflicting with
+// existing ones, by name or by account, cloud access role, and access type,
+// are skipped unless --overwrite is passed.
+func (c *Cmd) ImportFavorites(cCtx *cli.Context) error {
+	// downloads go through the same proxy and tls settings as requests to kion
+	imported, err := helper.ReadFavorites(cCtx.Context, c.client.HTTPClient, cCtx.Args().First())
+	if err != nil {
+		return err
+	}
+	for i := range imported {
+		if slices.Contains(helper.FavoriteSubcommands, imported[i].Name) {
+			return fmt.Errorf("%q is reserved for the favorite %v subcommand", imported[i].Name, imported[i].Name)
+		}
+		// favorites without an access type are cli favorites
+		if imported[i].AccessType == "" {
+			imported[i].AccessType = "cli"
+		}
+	}
+
+	if cCtx.Bool("upstream") {
+		return c.importUpstream(cCtx, imported)
+	}
+
+	// compare favorites without an access type as the cli favorites they are
+	existing := slices.Clone(c.config.Favorites)
+	for i := range existing {
+		if existing[i].AccessType == "" {
+			existing[i].AccessType = "cli"
+		}
+	}
+	_, favorites, err := helper.CombineFavorites(imported, existing)
+	if err != nil {
+		return err
+	}
+
+	overwrite := cCtx.Bool("overwrite")
+	chosen := favorites.LocalOnly
+	for _, f := range favorites.LocalOnly {
+		fmt.Printf(" - %s %s\n", f.Name, color.GreenString("(new)"))
+	}
+	for _, f := range favorites.ConflictsLocal {
+		if overwrite {
+			fmt.Printf(" - %s %s\n", f.Name, color.RedString("(replaces a local favorite)"))
+		} else {
+			fmt.Printf(" - %s %s\n", f.Name, color.YellowString("(conflicts with a local favorite, skipped)"))
+		}
+	}
+	if overwrite {
+		chosen = append(chosen, favorites.ConflictsLocal...)
+	}
+	if len(chosen) == 0 {
+		color.Green("\nNo favorites to import, all are already in the local config or conflict with it.")
+		return nil
+	}
+
+	err = c.editLocalFavorites(cCtx, func(local []structs.Favorite) ([]structs.Favorite, error) {
+		return helper.AddImportedFavorites(local, chosen, imported), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	color.Green("\nImported %d favorites", len(chosen))
+	return nil
+}
+
+// importUpstream pushes imported favorites to Kion once confirmed, the way
+// `kion util push-favorites` does. Fields Kion doesn't store, like region and
+// service, are not pushed.
+func (c *Cmd) importUpstream(cCtx *cli.Context, imported []structs.Favorite) error {
+	apiFavorites, err := c.upstreamFavorites(cCtx)
+	if err != nil {
+		return err
+	}
+	_, favorites, err := helper.CombineFavorites(imported, apiFavorites)
+	if err != nil {
+		return err
+	}
+
+	overwrite := cCtx.Bool("overwrite")
+	changes := len(favorites.LocalOnly) + len(favorites.UnaliasedLocal)
+	if overwrite {
+		changes += len(favorites.ConflictsLocal)
+	}
+
+	// build the prompt message
+	prompt := fmt.Sprintf("\nThe following favorites will be pushed to Kion (%v):\n\n", c.config.Kion.URL)
+	for _, f := range favorites.LocalOnly {
+		prompt += fmt.Sprintf(" - %s %s\n", f.Name, color.GreenString("(new)"))
+	}
+	for _, f := range favorites.UnaliasedLocal {
+		prompt += fmt.Sprintf(" - %s %s\n", f.Name, color.YellowString("(will update alias on existing favorite)"))
+	}
+	for _, f := range favorites.ConflictsLocal {
+		if overwrite {
+			prompt += fmt.Sprintf(" - %s %s\n", f.Name, color.RedString("(upstream conflict, will overwrite)"))
+		} else {
+			prompt += fmt.Sprintf(" - %s %s\n", f.Name, color.YellowString("(upstream conflict, skipped)"))
+		}
+	}
+	if changes == 0 {
+		fmt.Print(prompt)
+		color.Green("\nNo favorites to push, all are already in Kion or conflict with it.")
+		return nil
+	}
+	if cCtx.Bool("yes") {
+		fmt.Println(prompt)
+	}
+	prompt += "\nDo you want to continue?"
+
+	ok, err := confirmUpstream(cCtx, prompt)
+	if !ok || err != nil {
+		fmt.Println("\nAborting, no favorites were changed.")
+		return err
+	}
+
+	var errs []error
+	errs = append(errs, c.createUpstreamFavorite(cCtx, favorites.LocalOnly))
+	errs = append(errs, c.createUpstreamFavorite(cCtx, favorites.UnaliasedLocal))
+	if overwrite {
+		errs = append(errs, c.deleteUpstreamFavorites(cCtx, favorites.ConflictsUpstream))
+		errs = append(errs, c.createUpstreamFavorite(cCtx, favorites.ConflictsLocal))
+	}
+	return errors.Join(errs...)
+}
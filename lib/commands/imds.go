@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kionsoftware/kion-cli/lib/helper"
+	"github.com/urfave/cli/v2"
+)
+
+// IMDS runs a local emulation of the EC2 instance metadata service, serving
+// auto-refreshing STAKs for the selected cloud access role to tools that only
+// know how to read credentials from IMDSv2. It runs until interrupted.
+func (c *Cmd) IMDS(cCtx *cli.Context) error {
+	car, region, err := c.selectTarget(cCtx)
+	if err != nil {
+		return err
+	}
+	shell, err := c.shell()
+	if err != nil {
+		return err
+	}
+
+	// credentials are served under the iam role name, which favorites and
+	// flags don't carry so look up the full car
+	if car.AwsIamRoleName == "" {
+		if err := c.setAuthToken(cCtx); err != nil {
+			return err
+		}
+		name := car.AccountName
+		if car.AccountNumber != "" {
+			car, err = c.client.GetCARByNameAndAccount(cCtx.Context, car.Name, car.AccountNumber)
+		} else {
+			car, err = c.client.GetCARByNameAndAlias(cCtx.Context, car.Name, car.AccountAlias)
+		}
+		if err != nil {
+			return err
+		}
+		if car.AccountName == "" {
+			car.AccountName = name
+		}
+	}
+
+	// listen before fetching keys so a bad address fails fast
+	ln, err := listenLoopback(cCtx.String("address"))
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	// grab the first stak up front so any auth prompts happen now
+	creds, err := c.primeCredentials(cCtx, car)
+	if err != nil {
+		return err
+	}
+
+	// the exports go to stdout so they can be redirected, all else to stderr
+	endpoint := fmt.Sprintf("http://%s/", ln.Addr())
+	if err := helper.PrintIMDSEnv(os.Stdout, endpoint, region, shell); err != nil {
+		return err
+	}
+
+	return c.serveUntilDone(cCtx, car, ln, helper.NewIMDSHandler(creds, car.AwsIamRoleName, region))
+}
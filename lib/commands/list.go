@@ -0,0 +1,263 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kionsoftware/kion-cli/lib/helper"
+	"github.com/kionsoftware/kion-cli/lib/kion"
+	"github.com/urfave/cli/v2"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+//                                                                            //
+//  Helpers                                                                   //
+//                                                                            //
+////////////////////////////////////////////////////////////////////////////////
+
+// projectRecord is a project as printed by `kion list projects`.
+type projectRecord struct {
+	ID            uint   `json:"id" yaml:"id"`
+	Name          string `json:"name" yaml:"name"`
+	Description   string `json:"description,omitempty" yaml:"description,omitempty"`
+	DefaultRegion string `json:"default_aws_region,omitempty" yaml:"default_aws_region,omitempty"`
+	Archived      bool   `json:"archived" yaml:"archived"`
+}
+
+// accountRecord is an account as printed by `kion list accounts`.
+type accountRecord struct {
+	Number    string `json:"account_number" yaml:"account_number"`
+	Name      string `json:"account_name" yaml:"account_name"`
+	Alias     string `json:"account_alias,omitempty" yaml:"account_alias,omitempty"`
+	Type      string `json:"account_type,omitempty" yaml:"account_type,omitempty"`
+	TypeID    uint   `json:"account_type_id" yaml:"account_type_id"`
+	ProjectID uint   `json:"project_id" yaml:"project_id"`
+	Project   string `json:"project,omitempty" yaml:"project,omitempty"`
+}
+
+// carRecord is a cloud access role as printed by `kion list cars`.
+type carRecord struct {
+	Name          string `json:"cloud_access_role" yaml:"cloud_access_role"`
+	AccountNumber string `json:"account_number" yaml:"account_number"`
+	AccountName   string `json:"account_name" yaml:"account_name"`
+	AccountAlias  string `json:"account_alias,omitempty" yaml:"account_alias,omitempty"`
+	AccountType   string `json:"account_type,omitempty" yaml:"account_type,omitempty"`
+	IAMRole       string `json:"aws_iam_role_name,omitempty" yaml:"aws_iam_role_name,omitempty"`
+	WebAccess     bool   `json:"web_access" yaml:"web_access"`
+	STAKAccess    bool   `json:"short_term_access_keys" yaml:"short_term_access_keys"`
+	ProjectID     uint   `json:"project_id" yaml:"project_id"`
+	Project       string `json:"project,omitempty" yaml:"project,omitempty"`
+}
+
+// matchesAny reports whether the value matches one of the comma separated
+// glob patterns.
+func matchesAny(patterns string, value string) (bool, error) {
+	for _, pattern := range strings.Split(patterns, ",") {
+		matched, err := path.Match(strings.TrimSpace(pattern), value)
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// listProjects returns projects by ID, limited to those matching the
+// --project patterns if set.
+func (c *Cmd) listProjects(cCtx *cli.Context) ([]kion.Project, map[uint]string, error) {
+	projects, err := c.client.GetProjects(cCtx.Context)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := make(map[uint]string)
+	for _, project := range projects {
+		names[project.ID] = project.Name
+	}
+
+	patterns := cCtx.String("project")
+	if patterns == "" {
+		return projects, names, nil
+	}
+	var matched []kion.Project
+	for _, project := range projects {
+		ok, err := matchesAny(patterns, project.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			matched = append(matched, project)
+		}
+	}
+	return matched, names, nil
+}
+
+// listCARS returns the cloud access roles of the authenticated user that pass
+// the filters set on the command, with the names of all projects by ID.
+func (c *Cmd) listCARS(cCtx *cli.Context) ([]kion.CAR, map[uint]string, error) {
+	err := c.setAuthToken(cCtx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	projects, names, err := c.listProjects(cCtx)
+	if err != nil {
+		return nil, nil, err
+	}
+	cars, err := c.client.GetCARS(cCtx.Context, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	accountType := cCtx.String("account-type")
+	var filtered []kion.CAR
+	for _, car := range cars {
+		if cCtx.String("project") != "" && !slices.ContainsFunc(projects, func(p kion.Project) bool { return p.ID == car.ProjectID }) {
+			continue
+		}
+		if accountType != "" && !strings.EqualFold(car.AccountType, accountType) && strconv.FormatUint(uint64(car.AccountTypeID), 10) != accountType {
+			continue
+		}
+		if cCtx.Bool("web-access") && !car.WebAccess {
+			continue
+		}
+		if cCtx.Bool("stak-access") && !car.ShortTermAccessKeys {
+			continue
+		}
+		filtered = append(filtered, car)
+	}
+
+	// keep listings stable between runs
+	slices.SortStableFunc(filtered, func(a, b kion.CAR) int {
+		if n := strings.Compare(a.AccountNumber, b.AccountNumber); n != 0 {
+			return n
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return filtered, names, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+//                                                                            //
+//  Commands                                                                  //
+//                                                                            //
+////////////////////////////////////////////////////////////////////////////////
+
+// ListProjects prints the projects in Kion.
+func (c *Cmd) ListProjects(cCtx *cli.Context) error {
+	output := cCtx.String("output")
+	if err := helper.ValidateListOutput(output); err != nil {
+		return err
+	}
+
+	err := c.setAuthToken(cCtx)
+	if err != nil {
+		return err
+	}
+	projects, _, err := c.listProjects(cCtx)
+	if err != nil {
+		return err
+	}
+	slices.SortStableFunc(projects, func(a, b kion.Project) int { return strings.Compare(a.Name, b.Name) })
+
+	records := make([]projectRecord, 0, len(projects))
+	var rows [][]string
+	for _, project := range projects {
+		records = append(records, projectRecord{
+			ID:            project.ID,
+			Name:          project.Name,
+			Description:   project.Description,
+			DefaultRegion: project.DefaultAwsRegion,
+			Archived:      project.Archived,
+		})
+		rows = append(rows, []string{fmt.Sprint(project.ID), project.Name, project.DefaultAwsRegion, strconv.FormatBool(project.Archived)})
+	}
+
+	return helper.PrintList(os.Stdout, output, []string{"ID", "NAME", "DEFAULT_REGION", "ARCHIVED"}, rows, records)
+}
+
+// ListAccounts prints the accounts the authenticated user has cloud access
+// roles on.
+func (c *Cmd) ListAccounts(cCtx *cli.Context) error {
+	output := cCtx.String("output")
+	if err := helper.ValidateListOutput(output); err != nil {
+		return err
+	}
+
+	cars, projects, err := c.listCARS(cCtx)
+	if err != nil {
+		return err
+	}
+
+	records := make([]accountRecord, 0, len(cars))
+	var rows [][]string
+	for _, car := range cars {
+		if slices.ContainsFunc(records, func(r accountRecord) bool { return r.Number == car.AccountNumber }) {
+			continue
+		}
+		records = append(records, accountRecord{
+			Number:    car.AccountNumber,
+			Name:      car.AccountName,
+			Alias:     car.AccountAlias,
+			Type:      car.AccountType,
+			TypeID:    car.AccountTypeID,
+			ProjectID: car.ProjectID,
+			Project:   projects[car.ProjectID],
+		})
+		accountType := car.AccountType
+		if accountType == "" {
+			accountType = fmt.Sprint(car.AccountTypeID)
+		}
+		rows = append(rows, []string{car.AccountNumber, car.AccountName, car.AccountAlias, accountType, projects[car.ProjectID]})
+	}
+
+	return helper.PrintList(os.Stdout, output, []string{"ACCOUNT", "NAME", "ALIAS", "TYPE", "PROJECT"}, rows, records)
+}
+
+// ListCARS prints the cloud access roles of the authenticated user.
+func (c *Cmd) ListCARS(cCtx *cli.Context) error {
+	output := cCtx.String("output")
+	if err := helper.ValidateListOutput(output); err != nil {
+		return err
+	}
+
+	cars, projects, err := c.listCARS(cCtx)
+	if err != nil {
+		return err
+	}
+
+	records := make([]carRecord, 0, len(cars))
+	var rows [][]string
+	for _, car := range cars {
+		records = append(records, carRecord{
+			Name:          car.Name,
+			AccountNumber: car.AccountNumber,
+			AccountName:   car.AccountName,
+			AccountAlias:  car.AccountAlias,
+			AccountType:   car.AccountType,
+			IAMRole:       car.AwsIamRoleName,
+			WebAccess:     car.WebAccess,
+			STAKAccess:    car.ShortTermAccessKeys,
+			ProjectID:     car.ProjectID,
+			Project:       projects[car.ProjectID],
+		})
+		rows = append(rows, []string{
+			car.AccountNumber,
+			car.AccountAlias,
+			car.Name,
+			car.AwsIamRoleName,
+			strconv.FormatBool(car.WebAccess),
+			strconv.FormatBool(car.ShortTermAccessKeys),
+			projects[car.ProjectID],
+		})
+	}
+
+	return helper.PrintList(os.Stdout, output, []string{"ACCOUNT", "ALIAS", "CAR", "IAM_ROLE", "WEB", "STAK", "PROJECT"}, rows, records)
+}
@@ -2,8 +2,16 @@ package commands
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/fatih/color"
+
 	"github.com/kionsoftware/kion-cli/lib/helper"
 	"github.com/kionsoftware/kion-cli/lib/kion"
 	"github.com/kionsoftware/kion-cli/lib/structs"
@@ -13,6 +21,11 @@ import (
 // RunCommand generates creds for an AWS account then executes the user
 // provided command with said credentials set.
 func (c *Cmd) RunCommand(cCtx *cli.Context) error {
+	// run against many targets at once if asked
+	if isFanOut(cCtx) {
+		return c.runFanOut(cCtx)
+	}
+
 	// set vars for easier access
 	favName := cCtx.String("favorite")
 	accNum := cCtx.String("account")
@@ -56,7 +69,7 @@ func (c *Cmd) RunCommand(cCtx *cli.Context) error {
 
 		// take the region flag over the favorite region
 		targetRegion := region
-		if targetRegion == "" {
+		if !cCtx.IsSet("region") && favorite.Region != "" {
 			targetRegion = favorite.Region
 		}
 
@@ -88,3 +101,238 @@ func (c *Cmd) RunCommand(cCtx *cli.Context) error {
 
 	return nil
 }
+
+// runTarget is one account and cloud access role a fan-out run executes the
+// command against.
+type runTarget struct {
+	label  string
+	car    kion.CAR
+	region string
+}
+
+// isFanOut reports whether the run command targets many accounts at once.
+func isFanOut(cCtx *cli.Context) bool {
+	return cCtx.String("favorites") != "" || len(cCtx.StringSlice("accounts")) > 0 || cCtx.String("project") != ""
+}
+
+// runTargets resolves the targets of a fan-out run from favorites matching
+// the --favorites patterns, or the --car on each of the --accounts or the
+// accounts in --project.
+func (c *Cmd) runTargets(cCtx *cli.Context) ([]runTarget, error) {
+	region := c.config.Kion.DefaultRegion
+
+	if patterns := cCtx.String("favorites"); patterns != "" {
+		favorites, err := c.getFavorites(cCtx)
+		if err != nil {
+			return nil, err
+		}
+		var targets []runTarget
+		for _, favorite := range favorites {
+			if favorite.CloudServiceProvider != "" && favorite.CloudServiceProvider != "aws" {
+				continue
+			}
+			matched := false
+			for _, pattern := range strings.Split(patterns, ",") {
+				if ok, err := path.Match(strings.TrimSpace(pattern), favorite.Name); err != nil {
+					return nil, fmt.Errorf("invalid favorites pattern %q: %w", pattern, err)
+				} else if ok {
+					matched = true
+				}
+			}
+			if !matched {
+				continue
+			}
+			target := runTarget{
+				label:  favorite.Name,
+				car:    kion.CAR{Name: favorite.CAR, AccountNumber: favorite.Account},
+				region: region,
+			}
+			if !cCtx.IsSet("region") && favorite.Region != "" {
+				target.region = favorite.Region
+			}
+			targets = append(targets, target)
+		}
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("no AWS favorites match %q", patterns)
+		}
+		return targets, nil
+	}
+
+	// gather the wanted account numbers
+	carName := cCtx.String("car")
+	var accounts []string
+	for _, account := range cCtx.StringSlice("accounts") {
+		accounts = append(accounts, strings.Split(account, ",")...)
+	}
+	if projectName := cCtx.String("project"); projectName != "" {
+		projects, err := c.client.GetProjects(cCtx.Context)
+		if err != nil {
+			return nil, err
+		}
+		index := slices.IndexFunc(projects, func(p kion.Project) bool { return p.Name == projectName })
+		if index < 0 {
+			return nil, fmt.Errorf("project not found: %v", projectName)
+		}
+		onProject, err := c.client.GetAccountsOnProject(cCtx.Context, projects[index].ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, account := range onProject {
+			accounts = append(accounts, account.Number)
+		}
+	}
+
+	// find the car on each account, also giving us account aliases
+	cars, err := c.client.GetCARS(cCtx.Context, "")
+	if err != nil {
+		return nil, err
+	}
+	var targets []runTarget
+	var missing []string
+	for _, account := range accounts {
+		account = strings.TrimSpace(account)
+		if account == "" || slices.ContainsFunc(targets, func(t runTarget) bool { return t.car.AccountNumber == account }) {
+			continue
+		}
+		index := slices.IndexFunc(cars, func(car kion.CAR) bool {
+			return car.AccountNumber == account && car.Name == carName && car.ShortTermAccessKeys
+		})
+		if index < 0 {
+			missing = append(missing, account)
+			continue
+		}
+		car := cars[index]
+		label := car.AccountAlias
+		if label == "" {
+			label = car.AccountNumber
+		}
+		targets = append(targets, runTarget{label: label, car: car, region: region})
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("no short term access keys for %v on accounts: %v", carName, strings.Join(missing, ", "))
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("no accounts to run against")
+	}
+
+	return targets, nil
+}
+
+// runFanOut runs the command once per target, at most --parallel at a time,
+// with output prefixed by the target. A summary is printed once all are done
+// and the run fails with the highest exit code if any target failed.
+func (c *Cmd) runFanOut(cCtx *cli.Context) error {
+	// authenticate once up front so any prompt happens before the fan-out
+	err := c.setAuthToken(cCtx)
+	if err != nil {
+		return err
+	}
+
+	targets, err := c.runTargets(cCtx)
+	if err != nil {
+		return err
+	}
+
+	parallel := cCtx.Int("parallel")
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	// pad prefixes so output lines up
+	width := 0
+	for _, target := range targets {
+		width = max(width, len(target.label))
+	}
+
+	var outMu sync.Mutex
+	var wg sync.WaitGroup
+	results := make([]helper.RunResult, len(targets))
+	slots := make(chan struct{}, parallel)
+	for i, target := range targets {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			start := time.Now()
+			prefix := fmt.Sprintf("%-*s | ", width, target.label)
+			stdout := helper.NewPrefixWriter(os.Stdout, &outMu, prefix)
+			stderr := helper.NewPrefixWriter(os.Stderr, &outMu, prefix)
+
+			result := helper.RunResult{Target: target.label, Account: target.car.AccountNumber, CAR: target.car.Name}
+			stak, err := c.fanOutSTAK(cCtx, target.car)
+			if err == nil {
+				result.ExitCode, err = helper.RunCommandWithOutput(cCtx.Context, stak, target.region, stdout, stderr, cCtx.Args().First(), cCtx.Args().Tail()...)
+			}
+			result.Err = err
+			result.Duration = time.Since(start)
+			stdout.Flush()
+			stderr.Flush()
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	// summarize on stderr so stdout carries only command output
+	fmt.Fprintln(os.Stderr)
+	if err := helper.PrintRunSummary(os.Stderr, results); err != nil {
+		return err
+	}
+
+	failed := 0
+	code := 0
+	for _, result := range results {
+		if result.Failed() {
+			failed++
+			code = max(code, result.ExitCode, 1)
+		}
+	}
+	if failed > 0 {
+		return cli.Exit(color.RedString("\nError: %d of %d targets failed", failed, len(results)), code)
+	}
+	return nil
+}
+
+// fanOutSTAK returns keys for a fan-out target, preferring fresh cached keys.
+// Auth and cache access are serialized between targets while keys are minted
+// concurrently.
+func (c *Cmd) fanOutSTAK(cCtx *cli.Context, car kion.CAR) (kion.STAK, error) {
+	cachedSTAK, client, err := c.fanOutClient(cCtx, car)
+	if err != nil || client == nil {
+		return cachedSTAK, err
+	}
+
+	stak, err := client.GetSTAK(cCtx.Context, car.Name, car.AccountNumber, "")
+	if err != nil {
+		return kion.STAK{}, err
+	}
+
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+	if err := c.cache.SetStak(car.Name, car.AccountNumber, "", stak); err != nil {
+		return kion.STAK{}, err
+	}
+	return stak, nil
+}
+
+// fanOutClient returns fresh cached keys for a fan-out target, or if there
+// are none a copy of the client authenticated to mint them, so the request
+// is sent without holding the auth lock.
+func (c *Cmd) fanOutClient(cCtx *cli.Context, car kion.CAR) (kion.STAK, *kion.Client, error) {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	cachedSTAK, found, err := c.cache.GetStak(car.Name, car.AccountNumber, "")
+	if err != nil {
+		return kion.STAK{}, nil, err
+	}
+	if found && helper.STAKIsFresh(cachedSTAK, time.Now()) {
+		return cachedSTAK, nil, nil
+	}
+	if err := c.setAuthToken(cCtx); err != nil {
+		return kion.STAK{}, nil, err
+	}
+	client := *c.client
+	return kion.STAK{}, &client, nil
+}
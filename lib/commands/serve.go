@@ -0,0 +1,321 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/kionsoftware/kion-cli/lib/helper"
+	"github.com/kionsoftware/kion-cli/lib/kion"
+	"github.com/urfave/cli/v2"
+)
+
+// Serve runs a local endpoint compatible with the ECS container credentials
+// provider. STAKs for the selected cloud access role are refreshed from the
+// cache or Kion as they near expiration, so long running tools never see
+// expired keys. The endpoint runs until interrupted.
+func (c *Cmd) Serve(cCtx *cli.Context) error {
+	car, region, err := c.selectTarget(cCtx)
+	if err != nil {
+		return err
+	}
+	shell, err := c.shell()
+	if err != nil {
+		return err
+	}
+
+	// listen before fetching keys so a bad address fails fast
+	ln, err := listenLoopback(cCtx.String("address"))
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	token, err := helper.NewAuthToken()
+	if err != nil {
+		return err
+	}
+
+	// grab the first stak up front so any auth prompts happen now
+	creds, err := c.primeCredentials(cCtx, car)
+	if err != nil {
+		return err
+	}
+
+	// the exports go to stdout so they can be redirected, all else to stderr
+	uri := fmt.Sprintf("http://%s/credentials", ln.Addr())
+	if err := helper.PrintCredentialServerEnv(os.Stdout, uri, token, region, shell); err != nil {
+		return err
+	}
+
+	return c.serveUntilDone(cCtx, car, ln, helper.NewECSCredentialsHandler(creds, token))
+}
+
+// primeCredentials returns auto-refreshing credentials for the given cloud
+// access role after fetching the first STAK.
+func (c *Cmd) primeCredentials(cCtx *cli.Context, car kion.CAR) (*helper.RefreshingSTAK, error) {
+	creds := helper.NewRefreshingSTAK(c.stakSource(cCtx, car))
+	if _, err := creds.Get(cCtx.Context); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// serveUntilDone serves a credential handler until the command is
+// interrupted, reporting status to stderr.
+func (c *Cmd) serveUntilDone(cCtx *cli.Context, car kion.CAR, ln net.Listener, handler http.Handler) error {
+	if !c.config.Kion.QuietMode {
+		color.New(color.FgGreen).Fprintf(os.Stderr, "Serving credentials for %v on %v, press ctrl-c to stop\n", targetName(car), ln.Addr())
+	}
+
+	err := helper.ServeCredentials(cCtx.Context, ln, handler)
+	if !c.config.Kion.QuietMode {
+		color.New(color.FgGreen).Fprintf(os.Stderr, "Shutting down credential server for %v\n", targetName(car))
+	}
+
+	return err
+}
+
+// subShell starts a sub-shell with credentials for the given cloud access
+// role. Static keys are exported unless the endpoint mode is configured, which
+// points the shell at a local endpoint owned by this process that keeps them
+// refreshed. Either way the shell is warned shortly before its keys or the
+// Kion session run out.
+func (c *Cmd) subShell(cCtx *cli.Context, car kion.CAR, alias string, stak kion.STAK, region string) error {
+	shell, err := c.shell()
+	if err != nil {
+		return err
+	}
+
+	mode := c.config.Kion.SubshellCredentials
+	if mode != "" && mode != "static" && mode != "endpoint" {
+		return fmt.Errorf("unsupported subshell credentials mode: %v, expected static or endpoint", mode)
+	}
+
+	// ctrl-c in the shell also reaches this process, keep serving and watching
+	// through it until the shell exits
+	ctx, cancel := context.WithCancel(context.WithoutCancel(cCtx.Context))
+	defer cancel()
+	detached := *cCtx
+	detached.Context = ctx
+
+	// the shell owns the terminal from here on
+	c.noPrompt = true
+	defer func() { c.noPrompt = false }()
+
+	// static keys can't be renewed in place, so their expiration is watched too
+	if mode != "endpoint" {
+		go c.watchSession(&detached, stak.Expiration)
+		env := append(helper.STAKEnv(stak), helper.ExpirationEnv(stak.Expiration))
+		return helper.CreateSubShell(shell, car.AccountNumber, alias, car.Name, env, region)
+	}
+
+	ln, err := listenLoopback("127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	token, err := helper.NewAuthToken()
+	if err != nil {
+		return err
+	}
+
+	creds := helper.NewRefreshingSTAK(c.stakSource(&detached, car))
+	creds.Set(stak)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- helper.ServeCredentials(ctx, ln, helper.NewECSCredentialsHandler(creds, token))
+	}()
+	go c.watchSession(&detached, time.Time{})
+
+	// the expiration of the first keys would go stale once they're refreshed,
+	// status reads the live one from the endpoint instead
+	uri := fmt.Sprintf("http://%s/credentials", ln.Addr())
+	err = helper.CreateSubShell(shell, car.AccountNumber, alias, car.Name, helper.CredentialServerEnv(uri, token), region)
+
+	cancel()
+	if serveErr := <-served; err == nil {
+		err = serveErr
+	}
+
+	return err
+}
+
+// sessionWarning is how long before the Kion session can no longer be renewed,
+// or static keys expire, that a sub-shell is warned.
+const sessionWarning = 5 * time.Minute
+
+// watchSession warns once per session shortly before the Kion session can no
+// longer be renewed without signing in again. Unless zero, it also warns once
+// shortly before the static keys of the shell expire.
+func (c *Cmd) watchSession(cCtx *cli.Context, keysExpire time.Time) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	keysWarned := false
+	var warned time.Time
+	for {
+		if !keysExpire.IsZero() && !keysWarned && time.Until(keysExpire) <= sessionWarning {
+			keysWarned = true
+			color.New(color.FgYellow).Fprintf(os.Stderr, "\nAWS credentials in this shell expire at %v. Exit and start a new shell for fresh keys, or set subshell_credentials to 'endpoint' to keep them refreshed.\n", keysExpire.Local().Format(time.Kitchen))
+		}
+
+		deadline, found := c.renewalDeadline(cCtx)
+		if found && time.Until(deadline) <= sessionWarning && !deadline.Equal(warned) {
+			warned = deadline
+			if keysExpire.IsZero() {
+				color.New(color.FgYellow).Fprintf(os.Stderr, "\nYour Kion session ends at %v, AWS credentials in this shell will stop renewing after that. Run 'kion login' to extend it.\n", deadline.Local().Format(time.Kitchen))
+			} else {
+				color.New(color.FgYellow).Fprintf(os.Stderr, "\nYour Kion session ends at %v, new AWS credentials will need you to sign in again after that. Run 'kion login' to extend it.\n", deadline.Local().Format(time.Kitchen))
+			}
+		}
+
+		select {
+		case <-cCtx.Context.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// renewalDeadline returns when the Kion session in use can no longer be
+// renewed without user input. False if there is no such limit, as with an API
+// key or a configured password.
+func (c *Cmd) renewalDeadline(cCtx *cli.Context) (time.Time, bool) {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	if c.config.Kion.APIKey != "" || (c.config.Kion.Username != "" && c.config.Kion.Password != "") {
+		return time.Time{}, false
+	}
+
+	// keys served from the cache may not have needed a session yet
+	session := c.session
+	if session.Access.Token == "" {
+		cached, found, err := c.cachedSession(cCtx)
+		if err != nil || !found {
+			return time.Time{}, false
+		}
+		session = cached
+	}
+
+	access, refresh := sessionExpiries(session)
+	if refresh.After(access) {
+		return refresh, true
+	}
+	return access, true
+}
+
+// selectTarget determines the cloud access role to serve credentials for
+// from a favorite, the account and car flags, or the car selection wizard. The
+// region flag takes precedence over a favorite's region.
+func (c *Cmd) selectTarget(cCtx *cli.Context) (kion.CAR, string, error) {
+	region := c.config.Kion.DefaultRegion
+
+	// use a favorite if one was named
+	if favName := cCtx.String("favorite"); favName != "" {
+		favorites, err := c.getFavorites(cCtx)
+		if err != nil {
+			return kion.CAR{}, "", err
+		}
+		_, fMap := helper.MapFavs(favorites)
+		favorite, found := fMap[favName]
+		if !found {
+			return kion.CAR{}, "", errors.New("can't find favorite")
+		}
+		// take the region flag over the favorite region
+		if !cCtx.IsSet("region") && favorite.Region != "" {
+			region = favorite.Region
+		}
+		car := kion.CAR{
+			Name:          favorite.CAR,
+			AccountNumber: favorite.Account,
+			AccountName:   favorite.Name,
+		}
+		return car, region, nil
+	}
+
+	// use the account or alias and car if passed
+	carName := cCtx.String("car")
+	if carName != "" {
+		car := kion.CAR{
+			Name:          carName,
+			AccountNumber: cCtx.String("account"),
+			AccountAlias:  cCtx.String("alias"),
+		}
+		return car, region, nil
+	}
+
+	// else walk the user through the selection wizard
+	if err := c.setAuthToken(cCtx); err != nil {
+		return kion.CAR{}, "", err
+	}
+	var car kion.CAR
+	if err := helper.CARSelector(cCtx, c.client, &car); err != nil {
+		return kion.CAR{}, "", err
+	}
+
+	return car, region, nil
+}
+
+// stakSource returns a source of STAKs for the given cloud access role that
+// prefers fresh cached entries over minting new keys. Refreshes are reported
+// on stderr unless quieted or another process owns the terminal.
+func (c *Cmd) stakSource(cCtx *cli.Context, car kion.CAR) helper.STAKSource {
+	return func(context.Context) (kion.STAK, error) {
+		c.authMu.Lock()
+		defer c.authMu.Unlock()
+
+		cachedSTAK, found, err := c.cache.GetStak(car.Name, car.AccountNumber, car.AccountAlias)
+		if err != nil {
+			return kion.STAK{}, err
+		}
+		if found && helper.STAKIsFresh(cachedSTAK, time.Now()) {
+			return cachedSTAK, nil
+		}
+
+		stak, err := c.authStakCache(cCtx, car.Name, car.AccountNumber, car.AccountAlias)
+		if err != nil {
+			color.New(color.FgRed).Fprintf(os.Stderr, "Failed to refresh credentials for %v: %v\n", targetName(car), err)
+			return kion.STAK{}, err
+		}
+		if !c.config.Kion.QuietMode && !c.noPrompt {
+			fmt.Fprintf(os.Stderr, "Refreshed credentials for %v, valid until %v\n", targetName(car), stak.Expiration.Local().Format(time.Kitchen))
+		}
+
+		return stak, nil
+	}
+}
+
+// targetName returns a short description of a cloud access role and account
+// for status messages.
+func targetName(car kion.CAR) string {
+	account := car.AccountNumber
+	switch {
+	case car.AccountName != "" && account != "":
+		account = fmt.Sprintf("%v (%v)", car.AccountName, account)
+	case account == "":
+		account = car.AccountAlias
+	}
+	return fmt.Sprintf("%v in %v", car.Name, account)
+}
+
+// listenLoopback listens on the given address, which must be on a loopback
+// interface so that credentials are never exposed to the network.
+func listenLoopback(address string) (net.Listener, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %w", address, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("address %q is not a loopback address", address)
+	}
+	return net.Listen("tcp", address)
+}
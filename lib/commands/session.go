@@ -0,0 +1,214 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/kionsoftware/kion-cli/lib/kion"
+	"github.com/urfave/cli/v2"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+//                                                                            //
+//  Helpers                                                                   //
+//                                                                            //
+////////////////////////////////////////////////////////////////////////////////
+
+// identity describes who the Kion CLI is authenticated as and how.
+type identity struct {
+	User          string `json:"user,omitempty"`
+	IDMSID        uint   `json:"idms_id,omitempty"`
+	KionURL       string `json:"kion_url"`
+	KionVersion   string `json:"kion_version"`
+	AuthMethod    string `json:"auth_method"`
+	SessionExpiry string `json:"session_expiry,omitempty"`
+}
+
+// cachedSession returns the cached session for the configured Kion URL and
+// identity if one exists.
+func (c *Cmd) cachedSession(cCtx *cli.Context) (kion.Session, bool, error) {
+	return c.cache.GetSession(c.config.Kion.URL, cCtx.Uint("idms"), c.config.Kion.Username)
+}
+
+// sessionAuthMethod infers how a cached session was created.
+func sessionAuthMethod(session kion.Session) string {
+	if session.UserName != "" {
+		return "password"
+	}
+	return "saml"
+}
+
+////////////////////////////////////////////////////////////////////////////////
+//                                                                            //
+//  Commands                                                                  //
+//                                                                            //
+////////////////////////////////////////////////////////////////////////////////
+
+// Login authenticates the user with Kion and caches the resulting session so
+// later commands can run without prompting.
+func (c *Cmd) Login(cCtx *cli.Context) error {
+	if c.config.Kion.DisableCache {
+		color.Yellow("Caching is disabled, the session will not persist beyond this command.")
+	}
+
+	// drop the cached session to force a fresh authentication
+	if cCtx.Bool("force") {
+		session, found, err := c.cachedSession(cCtx)
+		if err != nil {
+			return err
+		}
+		if found {
+			err = c.cache.SetSession(session.Host, session.IDMSID, session.UserName, kion.Session{})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// an api key has no session to cache, just make sure it is accepted
+	if c.config.Kion.APIKey != "" {
+		if err := c.validateAPIKey(cCtx); err != nil {
+			return err
+		}
+		if !c.config.Kion.QuietMode {
+			fmt.Fprintln(os.Stderr, "Using the configured API key, no session to cache.")
+		}
+		return nil
+	}
+
+	err := c.setAuthToken(cCtx)
+	if err != nil {
+		return err
+	}
+
+	if !c.config.Kion.QuietMode {
+		session, found, err := c.cachedSession(cCtx)
+		if err != nil {
+			return err
+		}
+		if found && session.UserName != "" {
+			color.Green("Logged in to %v as %v, session expires %v", c.config.Kion.URL, session.UserName, session.Access.Expiry)
+		} else if found {
+			color.Green("Logged in to %v, session expires %v", c.config.Kion.URL, session.Access.Expiry)
+		} else {
+			color.Green("Logged in to %v", c.config.Kion.URL)
+		}
+	}
+
+	return nil
+}
+
+// Logout removes all cached sessions for the configured Kion URL along with
+// any cached passwords tied to them.
+func (c *Cmd) Logout(cCtx *cli.Context) error {
+	// remove the password for an explicitly configured identity
+	if c.config.Kion.Username != "" {
+		err := c.cache.SetPassword(c.config.Kion.URL, cCtx.Uint("idms"), c.config.Kion.Username, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	// remove every session for the host along with its password
+	removed := 0
+	for {
+		session, found, err := c.cache.GetSession(c.config.Kion.URL, 0, "")
+		if err != nil {
+			return err
+		}
+		if !found {
+			break
+		}
+		err = c.cache.SetSession(session.Host, session.IDMSID, session.UserName, kion.Session{})
+		if err != nil {
+			return err
+		}
+		if session.UserName != "" {
+			err = c.cache.SetPassword(session.Host, session.IDMSID, session.UserName, "")
+			if err != nil {
+				return err
+			}
+		}
+		removed++
+	}
+
+	if !c.config.Kion.QuietMode {
+		if removed == 0 {
+			color.Yellow("No cached sessions found for %v", c.config.Kion.URL)
+		} else {
+			color.Green("Logged out of %v", c.config.Kion.URL)
+		}
+	}
+
+	return nil
+}
+
+// Whoami prints the identity the Kion CLI is authenticated as, the targeted
+// Kion, and when the session expires.
+func (c *Cmd) Whoami(cCtx *cli.Context) error {
+	output := cCtx.String("output")
+	if output != "text" && output != "json" {
+		return fmt.Errorf("unsupported output format: %v", output)
+	}
+
+	kionVersion, _ := cCtx.App.Metadata["kionVersion"].(string)
+	id := identity{
+		KionURL:     c.config.Kion.URL,
+		KionVersion: kionVersion,
+	}
+
+	if c.config.Kion.APIKey != "" {
+		id.AuthMethod = "api key"
+	} else {
+		session, found, err := c.cachedSession(cCtx)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("not logged in to %v, run 'kion login' first", c.config.Kion.URL)
+		}
+
+		// report the refresh expiry if the access token can still be renewed
+		expiry := session.Access.Expiry
+		expiration, err := time.Parse(sessionTimeFormat, session.Access.Expiry)
+		if err != nil || !expiration.After(time.Now()) {
+			refreshExp, err := time.Parse(sessionTimeFormat, session.Refresh.Expiry)
+			if err != nil || !refreshExp.After(time.Now()) {
+				return fmt.Errorf("session for %v has expired, run 'kion login' to authenticate again", c.config.Kion.URL)
+			}
+			expiry = session.Refresh.Expiry
+		}
+
+		id.User = session.UserName
+		id.IDMSID = session.IDMSID
+		id.AuthMethod = sessionAuthMethod(session)
+		id.SessionExpiry = expiry
+	}
+
+	if output == "json" {
+		jsonData, err := json.MarshalIndent(id, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(jsonData))
+		return nil
+	}
+
+	user := id.User
+	if user == "" {
+		user = "[unknown]"
+	}
+	fmt.Printf(" user: %v\n", user)
+	if id.IDMSID != 0 {
+		fmt.Printf(" idms: %v\n", id.IDMSID)
+	}
+	fmt.Printf(" auth method: %v\n kion url: %v\n kion version: %v\n", id.AuthMethod, id.KionURL, id.KionVersion)
+	if id.SessionExpiry != "" {
+		fmt.Printf(" session expiry: %v\n", id.SessionExpiry)
+	}
+
+	return nil
+}
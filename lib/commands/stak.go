@@ -18,7 +18,6 @@ func (c *Cmd) GenStaks(cCtx *cli.Context) error {
 	var stak kion.STAK
 
 	// set vars for easier access
-	endpoint := c.config.Kion.URL
 	carName := cCtx.String("car")
 	accNum := cCtx.String("account")
 	accAlias := cCtx.String("alias")
@@ -53,12 +52,12 @@ func (c *Cmd) GenStaks(cCtx *cli.Context) error {
 			}
 
 			if accNum != "" {
-				car, err = kion.GetCARByNameAndAccount(endpoint, c.config.Kion.APIKey, carName, accNum)
+				car, err = c.client.GetCARByNameAndAccount(cCtx.Context, carName, accNum)
 				if err != nil {
 					return err
 				}
 			} else {
-				car, err = kion.GetCARByNameAndAlias(endpoint, c.config.Kion.APIKey, carName, accAlias)
+				car, err = c.client.GetCARByNameAndAlias(cCtx.Context, carName, accAlias)
 				if err != nil {
 					return err
 				}
@@ -72,7 +71,7 @@ func (c *Cmd) GenStaks(cCtx *cli.Context) error {
 		}
 
 		// run through the car selector to fill any gaps
-		err = helper.CARSelector(cCtx, &car)
+		err = helper.CARSelector(cCtx, c.client, &car)
 		if err != nil {
 			return err
 		}
@@ -103,9 +102,18 @@ func (c *Cmd) GenStaks(cCtx *cli.Context) error {
 		// NOTE: do not use os.Stderr here else credentials can be written to logs
 		return helper.PrintCredentialProcess(os.Stdout, stak)
 	case "print":
-		return helper.PrintSTAK(os.Stdout, stak, region)
+		shell, err := c.shell()
+		if err != nil {
+			return err
+		}
+		// cached keys skip the car lookup, describe them from the flags
+		if car.Name == "" {
+			car = kion.CAR{Name: carName, AccountNumber: accNum, AccountAlias: accAlias}
+		}
+		details := helper.STAKDetails{STAK: stak, CAR: car, Region: region, Shell: shell}
+		return helper.PrintSTAKFormat(os.Stdout, cCtx.String("format"), details)
 	case "save":
-		return helper.SaveAWSCreds(stak, car)
+		return helper.SaveAWSCreds(stak, car, c.config.Kion.AWSProfileTemplate, region, c.config.Kion.SaveRegion)
 	case "subshell":
 		if !c.config.Kion.QuietMode {
 			if err := helper.PrintFavoriteConfig(os.Stdout, car, region, "cli"); err != nil {
@@ -118,7 +126,7 @@ func (c *Cmd) GenStaks(cCtx *cli.Context) error {
 		} else {
 			displayAlais = car.AccountName
 		}
-		return helper.CreateSubShell(car.AccountNumber, displayAlais, car.Name, stak, region)
+		return c.subShell(cCtx, car, displayAlais, stak, region)
 	default:
 		return nil
 	}
@@ -0,0 +1,154 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kionsoftware/kion-cli/lib/helper"
+	"github.com/urfave/cli/v2"
+)
+
+// credentialStatus describes the AWS credentials in use and how long they
+// remain valid.
+type credentialStatus struct {
+	AccountNumber    string `json:"account_number,omitempty"`
+	AccountName      string `json:"account_name,omitempty"`
+	CAR              string `json:"cloud_access_role"`
+	Region           string `json:"region,omitempty"`
+	Expiration       string `json:"expiration,omitempty"`
+	RemainingSeconds int64  `json:"remaining_seconds"`
+	Refreshing       bool   `json:"refreshing"`
+	Source           string `json:"source"`
+}
+
+// Status prints the account, cloud access role, region, and time remaining on
+// the credentials of the current Kion CLI sub-shell, or on cached keys for the
+// account and car passed as flags. It never contacts Kion so it is cheap
+// enough to run from a shell prompt.
+func (c *Cmd) Status(cCtx *cli.Context) error {
+	output := cCtx.String("output")
+	if output != "text" && output != "json" && output != "prompt" {
+		return fmt.Errorf("unsupported output format: %v", output)
+	}
+
+	// describe the sub-shell unless asked about specific cached keys
+	status := credentialStatus{
+		AccountNumber: os.Getenv("KION_ACCOUNT_NUM"),
+		AccountName:   os.Getenv("KION_ACCOUNT_ALIAS"),
+		CAR:           os.Getenv("KION_CAR"),
+		Region:        os.Getenv("AWS_REGION"),
+	}
+	fromFlags := cCtx.String("car") != ""
+	if fromFlags {
+		status = credentialStatus{
+			AccountNumber: cCtx.String("account"),
+			AccountName:   cCtx.String("alias"),
+			CAR:           cCtx.String("car"),
+			Region:        c.config.Kion.DefaultRegion,
+		}
+	}
+	if status.CAR == "" {
+		// stay silent in prompts outside of a sub-shell
+		if output == "prompt" {
+			return nil
+		}
+		return errors.New("not in a Kion CLI sub-shell, pass --car with --account or --alias to check cached keys")
+	}
+
+	// prompts render often, don't risk a keyring prompt there
+	expiration, err := c.statusExpiration(cCtx, &status, fromFlags, output != "prompt")
+	if err != nil {
+		return err
+	}
+	if !expiration.IsZero() {
+		status.Expiration = expiration.UTC().Format(time.RFC3339)
+		status.RemainingSeconds = max(int64(time.Until(expiration).Seconds()), 0)
+	}
+
+	switch output {
+	case "json":
+		jsonData, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(jsonData))
+	case "prompt":
+		if !expiration.IsZero() {
+			fmt.Println(helper.FormatRemaining(time.Until(expiration)))
+		}
+	default:
+		account := status.AccountNumber
+		if status.AccountName != "" && account != "" {
+			account = fmt.Sprintf("%v (%v)", status.AccountName, account)
+		} else if account == "" {
+			account = status.AccountName
+		}
+		region := status.Region
+		if region == "" {
+			region = "[unset]"
+		}
+		fmt.Printf(" account: %v\n cloud access role: %v\n region: %v\n", account, status.CAR, region)
+		if expiration.IsZero() {
+			fmt.Println(" expires: [unknown]")
+		} else {
+			fmt.Printf(" expires: %v (%v remaining)\n", expiration.Local().Format(time.Kitchen), helper.FormatRemaining(time.Until(expiration)))
+		}
+		if status.Refreshing {
+			fmt.Println(" refreshed automatically while the sub-shell is open")
+		}
+	}
+
+	return nil
+}
+
+// statusExpiration determines when the credentials expire, preferring the
+// live value from a sub-shell's credential endpoint, then the expiration
+// exported into the sub-shell, then the cache if allowed. A zero time means
+// unknown.
+func (c *Cmd) statusExpiration(cCtx *cli.Context, status *credentialStatus, fromFlags bool, useCache bool) (time.Time, error) {
+	if !fromFlags {
+		uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+		if uri != "" {
+			expiration, err := helper.CredentialServerExpiration(cCtx.Context, uri, os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"))
+			if err == nil {
+				status.Refreshing = true
+				status.Source = "endpoint"
+				return expiration, nil
+			}
+		}
+		if value := os.Getenv("KION_STAK_EXPIRATION"); value != "" {
+			expiration, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid KION_STAK_EXPIRATION: %w", err)
+			}
+			status.Source = "environment"
+			return expiration, nil
+		}
+	}
+
+	if !useCache {
+		return time.Time{}, nil
+	}
+
+	// the cache is only opened when needed, it may prompt for a password
+	if err := c.initCache(cCtx); err != nil {
+		return time.Time{}, err
+	}
+	accAlias := ""
+	if status.AccountNumber == "" {
+		accAlias = status.AccountName
+	}
+	stak, found, err := c.cache.GetStak(status.CAR, status.AccountNumber, accAlias)
+	if err != nil {
+		return time.Time{}, err
+	}
+	status.Source = "cache"
+	if !found {
+		return time.Time{}, nil
+	}
+
+	return stak.Expiration, nil
+}
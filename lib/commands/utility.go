@@ -3,6 +3,11 @@ package commands
 import (
 	"errors"
 	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/kionsoftware/kion-cli/lib/helper"
@@ -20,11 +25,11 @@ import (
 // deleteUpstreamFavorites deletes favorites in the Kion API. It assumes you are
 // passing upstream defined favorites only as we don't want to delete local
 // only favorites.
-func (c *Cmd) deleteUpstreamFavorites(favorites []structs.Favorite) error {
+func (c *Cmd) deleteUpstreamFavorites(cCtx *cli.Context, favorites []structs.Favorite) error {
 	hasErrors := false
 	for _, f := range favorites {
 		fmt.Printf(" removing upstream favorite %s: ", f.Name)
-		_, err := kion.DeleteFavorite(c.config.Kion.URL, c.config.Kion.APIKey, f.Name)
+		err := c.client.DeleteFavorite(cCtx.Context, f.Name)
 		if err != nil {
 			color.Red("x %s\n", err)
 			hasErrors = true
@@ -41,12 +46,12 @@ func (c *Cmd) deleteUpstreamFavorites(favorites []structs.Favorite) error {
 // createUpstreamFavorite creates favorites in the Kion API. It assumes you are
 // passing locally defined favorites only as we convert the access type from
 // cli to api format.
-func (c *Cmd) createUpstreamFavorite(favorites []structs.Favorite) error {
+func (c *Cmd) createUpstreamFavorite(cCtx *cli.Context, favorites []structs.Favorite) error {
 	hasErrors := false
 	for _, f := range favorites {
 		fmt.Printf(" creating favorite %s: ", f.Name)
 		f.AccessType = kion.ConvertAccessType(f.AccessType)
-		_, _, err := kion.CreateFavorite(c.config.Kion.URL, c.config.Kion.APIKey, f)
+		_, err := c.client.CreateFavorite(cCtx.Context, f)
 		if err != nil {
 			color.Red("x %s\n", err)
 			hasErrors = true
@@ -60,6 +65,160 @@ func (c *Cmd) createUpstreamFavorite(favorites []structs.Favorite) error {
 	return nil
 }
 
+// credentialProcess returns the credential_process command that has the Kion
+// CLI print keys for an account and cloud access role, using the active Kion
+// CLI profile. The command is --kion-path if set, otherwise "kion" when that
+// resolves on $PATH to this binary so upgrades and moves keep working, and this
+// binary's absolute path as a last resort.
+func credentialProcess(cCtx *cli.Context, account string, car string) (string, error) {
+	exe := cCtx.String("kion-path")
+	if exe == "" {
+		self, err := os.Executable()
+		if err != nil {
+			return "", err
+		}
+		exe = self
+		if onPath, err := exec.LookPath("kion"); err == nil && sameFile(onPath, self) {
+			exe = "kion"
+		}
+	}
+	args := []string{exe}
+	if profile := cCtx.String("profile"); profile != "" {
+		args = append(args, "--profile", profile)
+	}
+	args = append(args, "stak", "--credential-process", "--account", account, "--car", car)
+
+	// quote anything the aws cli would otherwise split
+	for i, arg := range args {
+		if strings.ContainsAny(arg, " \t\"'") {
+			args[i] = `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+		}
+	}
+	return strings.Join(args, " "), nil
+}
+
+// sameFile reports whether both paths resolve to the same file.
+func sameFile(a string, b string) bool {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(aInfo, bInfo)
+}
+
+// awsProfiles builds the AWS config profiles to sync from favorites or every
+// cloud access role the user can generate keys for, named by the template.
+func (c *Cmd) awsProfiles(cCtx *cli.Context, source string, template string) ([]helper.AWSProfile, error) {
+	region := cCtx.String("region")
+	if region == "" {
+		region = c.config.Kion.DefaultRegion
+	}
+
+	// gather the fields each profile name can use
+	var targets []map[string]string
+	switch source {
+	case "favorites":
+		favorites, err := c.getFavorites(cCtx)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range favorites {
+			// only aws favorites used for keys can back a credential process
+			if f.CloudServiceProvider != "" && f.CloudServiceProvider != "aws" {
+				continue
+			}
+			if f.AccessType == "web" {
+				continue
+			}
+			fRegion := f.Region
+			if fRegion == "" {
+				fRegion = region
+			}
+			targets = append(targets, map[string]string{
+				"name":         f.Name,
+				"account":      f.Account,
+				"alias":        "",
+				"account_name": "",
+				"car":          f.CAR,
+				"region":       fRegion,
+			})
+		}
+	case "cars":
+		if err := c.setAuthToken(cCtx); err != nil {
+			return nil, err
+		}
+		cars, err := c.client.GetCARS(cCtx.Context, "")
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[string]bool)
+		for _, car := range cars {
+			key := car.AccountNumber + "/" + car.Name
+			if !car.ShortTermAccessKeys || seen[key] {
+				continue
+			}
+			seen[key] = true
+			name := car.AccountAlias
+			if name == "" {
+				name = car.AccountName
+			}
+			targets = append(targets, map[string]string{
+				"name":         name,
+				"account":      car.AccountNumber,
+				"alias":        car.AccountAlias,
+				"account_name": car.AccountName,
+				"car":          car.Name,
+				"region":       region,
+			})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported source: %v, expected favorites or cars", source)
+	}
+
+	var profiles []helper.AWSProfile
+	for _, fields := range targets {
+		name, err := helper.RenderProfileName(template, fields)
+		if err != nil {
+			return nil, err
+		}
+		process, err := credentialProcess(cCtx, fields["account"], fields["car"])
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, helper.AWSProfile{
+			Name:              name,
+			CredentialProcess: process,
+			Region:            fields["region"],
+		})
+	}
+
+	return profiles, nil
+}
+
+// printDiff prints a line diff of a file, colored like git.
+func printDiff(path string, label string, diff []string) {
+	if len(diff) == 0 {
+		return
+	}
+	fmt.Printf("\n--- %v\n+++ %v (%v)\n", path, path, label)
+	for _, line := range diff {
+		switch line[0] {
+		case '+':
+			color.Green(line)
+		case '-':
+			color.Red(line)
+		case '@':
+			color.Cyan(line)
+		default:
+			fmt.Println(line)
+		}
+	}
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 //                                                                            //
 //  Commands                                                                  //
@@ -74,8 +233,7 @@ func (c *Cmd) FlushCache(cCtx *cli.Context) error {
 // PushFavorites pushes the local favorites to a target instance of Kion.
 func (c *Cmd) PushFavorites(cCtx *cli.Context) error {
 	// Exit if not using a compatible Kion version.
-	if !cCtx.App.Metadata["useFavoritesAPI"].(bool) {
-		err := errors.New("favorites API is not enabled. This requires Kion version 3.13.5, 3.14.1 or higher")
+	if err := requireFavoritesAPI(cCtx); err != nil {
 		return err
 	}
 
@@ -96,7 +254,7 @@ func (c *Cmd) PushFavorites(cCtx *cli.Context) error {
 	}
 
 	// Get the combined list of favorites from the CLI config and the Kion API.
-	apiFavorites, _, err := kion.GetAPIFavorites(c.config.Kion.URL, c.config.Kion.APIKey)
+	apiFavorites, err := c.client.GetAPIFavorites(cCtx.Context)
 	if err != nil {
 		fmt.Printf("Error retrieving favorites from Kion API: %v\n", err)
 		return err
@@ -149,23 +307,23 @@ func (c *Cmd) PushFavorites(cCtx *cli.Context) error {
 	}
 
 	// Push new local-only favorites.
-	err = c.createUpstreamFavorite(favorites.LocalOnly)
+	err = c.createUpstreamFavorite(cCtx, favorites.LocalOnly)
 	if err != nil {
 		hasErrors = true
 	}
 
 	// Handle conflicts by deleting and recreating.
-	err = c.deleteUpstreamFavorites(favorites.ConflictsUpstream)
+	err = c.deleteUpstreamFavorites(cCtx, favorites.ConflictsUpstream)
 	if err != nil {
 		hasErrors = true
 	}
-	err = c.createUpstreamFavorite(favorites.ConflictsLocal)
+	err = c.createUpstreamFavorite(cCtx, favorites.ConflictsLocal)
 	if err != nil {
 		hasErrors = true
 	}
 
 	// Handle unaliased favorites (create will overwrite / update).
-	err = c.createUpstreamFavorite(favorites.UnaliasedLocal)
+	err = c.createUpstreamFavorite(cCtx, favorites.UnaliasedLocal)
 	if err != nil {
 		hasErrors = true
 	}
@@ -221,3 +379,240 @@ func (c *Cmd) DeleteLocalFavorites(cCtx *cli.Context) error {
 
 	return nil
 }
+
+// PullFavorites downloads favorites from Kion into the configuration file, for
+// the active profile or the one named by --to-profile. Favorites that
+// conflict with local ones replace them only when chosen, keeping the fields
+// Kion doesn't store.
+func (c *Cmd) PullFavorites(cCtx *cli.Context) error {
+	// Exit if not using a compatible Kion version.
+	if err := requireFavoritesAPI(cCtx); err != nil {
+		return err
+	}
+
+	err := c.setAuthToken(cCtx)
+	if err != nil {
+		return err
+	}
+	apiFavorites, err := c.client.GetAPIFavorites(cCtx.Context)
+	if err != nil {
+		return err
+	}
+
+	// compare against the favorites of the profile being written
+	configPath := cCtx.App.Metadata["configPath"].(string)
+	profileName := cCtx.String("profile")
+	if cCtx.IsSet("to-profile") {
+		profileName = cCtx.String("to-profile")
+	}
+	_, localFavorites, err := loadProfileFavorites(configPath, profileName)
+	if err != nil {
+		return err
+	}
+	for i := range localFavorites {
+		// compare favorites without an access type as the cli favorites they are
+		if localFavorites[i].AccessType == "" {
+			localFavorites[i].AccessType = "cli"
+		}
+	}
+	_, favorites, err := helper.CombineFavorites(localFavorites, apiFavorites)
+	if err != nil {
+		return err
+	}
+
+	// label what can be pulled, new favorites are chosen by default
+	var options, selected []string
+	var unaliased []structs.Favorite
+	pullable := make(map[string]structs.Favorite)
+	for _, f := range apiFavorites {
+		var label string
+		switch {
+		case f.Unaliased:
+			if !slices.Contains(favorites.UnaliasedUpstream, f) {
+				unaliased = append(unaliased, f)
+			}
+			continue
+		case slices.Contains(favorites.ConflictsUpstream, f):
+			index := slices.IndexFunc(localFavorites, func(l structs.Favorite) bool {
+				return l.Name == f.Name || helper.SameFavoriteTarget(l, f)
+			})
+			label = fmt.Sprintf("%s %s", f.Name, color.RedString("(replaces local %s)", localFavorites[index].Name))
+			if cCtx.Bool("overwrite") {
+				selected = append(selected, label)
+			}
+		case slices.ContainsFunc(localFavorites, func(l structs.Favorite) bool {
+			return l.Name == f.Name && helper.SameFavoriteTarget(l, f)
+		}):
+			continue
+		default:
+			label = fmt.Sprintf("%s %s", f.Name, color.GreenString("(new)"))
+			selected = append(selected, label)
+		}
+		options = append(options, label)
+		pullable[label] = f
+	}
+
+	for _, f := range unaliased {
+		fmt.Printf(" - %s (%s %s %s) %s\n", f.Name, f.Account, f.CAR, f.AccessType, color.YellowString("has no alias in Kion and can't be pulled"))
+	}
+	if len(options) == 0 {
+		color.Green("All favorites in Kion are already in the local config.\n")
+		return nil
+	}
+
+	// choose what to pull
+	if cCtx.Bool("yes") {
+		if len(selected) > 0 {
+			fmt.Printf("\nThe following favorites will be pulled from Kion (%v):\n\n", c.config.Kion.URL)
+		}
+		for _, label := range selected {
+			fmt.Printf(" - %s\n", label)
+		}
+	} else {
+		selected, err = helper.PromptMultiSelect(
+			fmt.Sprintf("Select favorites to pull from Kion (%v):", c.config.Kion.URL),
+			"Conflicting favorites replace the local ones, keeping their service, region, and firefox container.",
+			options,
+			selected,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	var pulled []structs.Favorite
+	for _, label := range selected {
+		f := pullable[label]
+		f.DescriptiveName = ""
+		pulled = append(pulled, f)
+	}
+	if len(pulled) == 0 {
+		fmt.Println("\nNo favorites were pulled.")
+		return nil
+	}
+
+	err = c.editProfileFavorites(cCtx, profileName, func(favorites []structs.Favorite) ([]structs.Favorite, error) {
+		return helper.MergeFavorites(favorites, pulled), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if profileName != "" {
+		color.Green("\nPulled %d favorites into profile %v in %v", len(pulled), profileName, configPath)
+	} else {
+		color.Green("\nPulled %d favorites into %v", len(pulled), configPath)
+	}
+	return nil
+}
+
+// SyncAWSConfig writes credential_process profiles for favorites or every
+// cloud access role into the AWS config file. Only profiles the Kion CLI wrote
+// are ever updated or pruned.
+func (c *Cmd) SyncAWSConfig(cCtx *cli.Context) error {
+	source := cCtx.String("source")
+	template := cCtx.String("name-template")
+	if template == "" {
+		template = "{name}"
+		if source == "cars" {
+			template = "{account}-{car}"
+		}
+	}
+
+	profiles, err := c.awsProfiles(cCtx, source, template)
+	if err != nil {
+		return err
+	}
+
+	path := cCtx.String("aws-config")
+	if path == "" {
+		path, err = helper.AWSConfigPath()
+		if err != nil {
+			return err
+		}
+	}
+
+	dryRun := cCtx.Bool("dry-run")
+	changes, diff, err := helper.SyncAWSConfig(path, profiles, cCtx.Bool("prune"), dryRun)
+	if err != nil {
+		return err
+	}
+
+	// show what changed, and on a dry run how the file would change
+	for _, change := range changes {
+		switch change.Action {
+		case "add":
+			color.Green(" + %v", change.Profile)
+		case "update":
+			color.Yellow(" ~ %v", change.Profile)
+		case "remove":
+			color.Red(" - %v", change.Profile)
+		case "skip":
+			fmt.Printf(" ! %v skipped, the profile exists but was not written by the Kion CLI\n", change.Profile)
+		}
+	}
+	if dryRun {
+		printDiff(path, "after sync", diff)
+	}
+
+	switch {
+	case len(diff) == 0:
+		color.Green("\nAWS config is up to date: %v", path)
+	case dryRun:
+		color.Green("\nDry run, %v was not changed", path)
+	default:
+		color.Green("\nAWS config updated: %v", path)
+	}
+
+	return nil
+}
+
+// PruneAWSCreds removes credentials profiles written by `stak --save` from the
+// AWS credentials file once their keys have expired, or all of them with
+// --all. Profiles the Kion CLI did not create are never touched.
+func (c *Cmd) PruneAWSCreds(cCtx *cli.Context) error {
+	path := cCtx.String("aws-credentials")
+	if path == "" {
+		var err error
+		path, err = helper.AWSCredentialsPath()
+		if err != nil {
+			return err
+		}
+	}
+
+	dryRun := cCtx.Bool("dry-run")
+	profiles, diff, err := helper.PruneAWSCreds(path, cCtx.Bool("all"), dryRun)
+	if err != nil {
+		return err
+	}
+
+	// show what was pruned, and on a dry run how the file would change
+	for _, profile := range profiles {
+		expires := "no recorded expiration"
+		if !profile.Expiration.IsZero() {
+			expires = "expires " + profile.Expiration.Local().Format(time.RFC1123)
+			if profile.Expiration.Before(time.Now()) {
+				expires = "expired " + profile.Expiration.Local().Format(time.RFC1123)
+			}
+		}
+		switch profile.Action {
+		case "remove":
+			color.Red(" - %v, %v", profile.Profile, expires)
+		case "keep":
+			fmt.Printf("   %v, %v\n", profile.Profile, expires)
+		}
+	}
+	if dryRun {
+		printDiff(path, "after prune", diff)
+	}
+
+	switch {
+	case len(diff) == 0:
+		color.Green("\nNo AWS credentials to prune: %v", path)
+	case dryRun:
+		color.Green("\nDry run, %v was not changed", path)
+	default:
+		color.Green("\nAWS credentials pruned: %v", path)
+	}
+
+	return nil
+}
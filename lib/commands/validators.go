@@ -8,6 +8,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 	"time"
 
@@ -27,12 +28,14 @@ type validationContext struct {
 	allPassed  bool
 }
 
-// newValidationContext creates a new validation context.
-func newValidationContext() *validationContext {
+// newValidationContext creates a new validation context. Checks are sent
+// over the provided transport so they match how the CLI reaches Kion.
+func newValidationContext(transport http.RoundTripper) *validationContext {
 	return &validationContext{
 		styles: styles.NewOutputStyles(),
 		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
+			Transport: transport,
+			Timeout:   10 * time.Second,
 		},
 		allPassed: true,
 	}
@@ -179,11 +182,11 @@ func (c *Cmd) checkCSRFEndpoint(ctx *validationContext) {
 }
 
 // loadMetadata loads and validates SAML metadata
-func (c *Cmd) loadMetadata(ctx *validationContext) (*samlTypes.EntityDescriptor, error) {
+func (c *Cmd) loadMetadata(cCtx *cli.Context, ctx *validationContext) (*samlTypes.EntityDescriptor, error) {
 	var metadata *samlTypes.EntityDescriptor
 	var err error
 	if strings.HasPrefix(c.config.Kion.SamlMetadataFile, "http") {
-		metadata, err = kion.DownloadSAMLMetadata(c.config.Kion.SamlMetadataFile)
+		metadata, err = c.client.DownloadSAMLMetadata(cCtx.Context, c.config.Kion.SamlMetadataFile)
 	} else {
 		metadata, err = kion.ReadSAMLMetadataFile(c.config.Kion.SamlMetadataFile)
 	}
@@ -358,7 +361,7 @@ func (c *Cmd) checkSSOURLReachability(ctx *validationContext, metadata *samlType
 
 // ValidateSAML validates SAML configuration and connectivity.
 func (c *Cmd) ValidateSAML(cCtx *cli.Context) error {
-	ctx := newValidationContext()
+	ctx := newValidationContext(c.client.HTTPClient.Transport)
 
 	// Header
 	fmt.Println()
@@ -378,7 +381,7 @@ func (c *Cmd) ValidateSAML(cCtx *cli.Context) error {
 	kionAccessible := c.checkKionConnectivity(ctx)
 
 	// Load and validate metadata
-	metadata, err := c.loadMetadata(ctx)
+	metadata, err := c.loadMetadata(cCtx, ctx)
 	if err == nil {
 		// Validate metadata structure
 		if c.validateMetadataStructure(ctx, metadata) {
@@ -436,9 +439,130 @@ func (c *Cmd) ValidateCmdStak(cCtx *cli.Context) error {
 	} else if cCtx.String("car") != "" && cCtx.String("account") == "" && cCtx.String("alias") == "" {
 		return errors.New("must specify --account OR --alias parameter when using --car")
 	}
+	if _, err := helper.LookupSTAKFormat(cCtx.String("format")); err != nil {
+		return err
+	}
+	if action, _ := getActionAndBuffer(cCtx); action == "save" {
+		// catch a bad profile name template before minting keys
+		placeholder := kion.CAR{Name: "car", AccountNumber: "account", AccountAlias: "alias", AccountName: "name", AwsIamRoleName: "role"}
+		if _, err := helper.AWSProfileName(c.config.Kion.AWSProfileTemplate, placeholder, "region"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateCmdFavorite validates the flags passed to the favorite command.
+func (c *Cmd) ValidateCmdFavorite(cCtx *cli.Context) error {
+	if _, err := helper.LookupSTAKFormat(cCtx.String("format")); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validateFavoriteFlags validates the flags shared by the favorite add and
+// edit commands.
+func validateFavoriteFlags(cCtx *cli.Context) error {
+	if cCtx.IsSet("access-type") {
+		accessType := cCtx.String("access-type")
+		if accessType != "cli" && accessType != "web" {
+			return fmt.Errorf("unsupported access type: %v, expected cli or web", accessType)
+		}
+	}
+	if cCtx.IsSet("account") && cCtx.String("account") == "" {
+		return errors.New("--account can not be empty")
+	}
+	if cCtx.IsSet("car") && cCtx.String("car") == "" {
+		return errors.New("--car can not be empty")
+	}
+	return nil
+}
+
+// ValidateCmdFavoriteAdd validates the flags passed to the favorite add
+// command.
+func (c *Cmd) ValidateCmdFavoriteAdd(cCtx *cli.Context) error {
+	if cCtx.NArg() > 1 {
+		if strings.HasPrefix(cCtx.Args().Get(1), "-") {
+			return errors.New("flags must be passed before the favorite name")
+		}
+		return errors.New("expected at most one favorite name")
+	}
+	if cCtx.Bool("upstream") && (cCtx.IsSet("region") || cCtx.IsSet("service") || cCtx.IsSet("firefox-container")) {
+		return errors.New("--region, --service, and --firefox-container are only stored in local favorites and can not be used with --upstream")
+	}
+	return validateFavoriteFlags(cCtx)
+}
+
+// ValidateCmdFavoriteRemove validates the arguments passed to the favorite
+// remove command.
+func (c *Cmd) ValidateCmdFavoriteRemove(cCtx *cli.Context) error {
+	if cCtx.NArg() != 1 {
+		return errors.New("expected the name of the favorite to remove")
+	}
+	return nil
+}
+
+// ValidateCmdFavoriteRename validates the arguments passed to the favorite
+// rename command.
+func (c *Cmd) ValidateCmdFavoriteRename(cCtx *cli.Context) error {
+	if cCtx.NArg() != 2 {
+		return errors.New("expected the current and new name of the favorite")
+	}
+	return nil
+}
+
+// ValidateCmdFavoriteEdit validates the flags passed to the favorite edit
+// command.
+func (c *Cmd) ValidateCmdFavoriteEdit(cCtx *cli.Context) error {
+	if cCtx.NArg() > 1 && strings.HasPrefix(cCtx.Args().Get(1), "-") {
+		return errors.New("flags must be passed before the favorite name")
+	}
+	if cCtx.NArg() != 1 {
+		return errors.New("expected the name of the favorite to edit")
+	}
+	changed := false
+	for _, flag := range []string{"account", "car", "access-type", "region", "service", "firefox-container"} {
+		changed = changed || cCtx.IsSet(flag)
+	}
+	if !changed {
+		return errors.New("nothing to change, pass at least one of --account, --car, --access-type, --region, --service, or --firefox-container")
+	}
+	return validateFavoriteFlags(cCtx)
+}
+
+// ValidateCmdFavoriteExport validates the flags passed to the favorite export
+// command.
+func (c *Cmd) ValidateCmdFavoriteExport(cCtx *cli.Context) error {
+	output := cCtx.String("output")
+	if !slices.Contains(helper.FavoriteOutputs, output) {
+		return fmt.Errorf("unsupported output format: %v, expected %v", output, strings.Join(helper.FavoriteOutputs, ", "))
+	}
 	return nil
 }
 
+// ValidateCmdFavoriteImport validates the arguments passed to the favorite
+// import command.
+func (c *Cmd) ValidateCmdFavoriteImport(cCtx *cli.Context) error {
+	if cCtx.NArg() > 1 && strings.HasPrefix(cCtx.Args().Get(1), "-") {
+		return errors.New("flags must be passed before the file or URL")
+	}
+	if cCtx.NArg() != 1 {
+		return errors.New("expected a file, URL, or - for stdin to import from")
+	}
+	return nil
+}
+
+// ValidateCmdServe validates the flags passed to the serve command.
+func (c *Cmd) ValidateCmdServe(cCtx *cli.Context) error {
+	if cCtx.String("favorite") != "" {
+		if cCtx.String("account") != "" || cCtx.String("alias") != "" || cCtx.String("car") != "" {
+			return errors.New("--favorite can not be combined with --account, --alias, or --car")
+		}
+		return nil
+	}
+	return c.ValidateCmdStak(cCtx)
+}
+
 // ValidateCmdConsole validates the flags passed to the console command.
 func (c *Cmd) ValidateCmdConsole(cCtx *cli.Context) error {
 	if cCtx.String("car") != "" {
@@ -451,27 +575,43 @@ func (c *Cmd) ValidateCmdConsole(cCtx *cli.Context) error {
 	return nil
 }
 
-// ValidateCmdRun validates the flags passed to the run command and sets the
-// favorites region as the default region if needed to ensure precedence.
+// ValidateCmdRun validates the flags passed to the run command, checking that
+// the command, favorite, account, and car flags are combined correctly.
 func (c *Cmd) ValidateCmdRun(cCtx *cli.Context) error {
-	// Validate that either a favorite is used or both account/alias and car are provided
-	if cCtx.String("favorite") == "" && ((cCtx.String("account") == "" && cCtx.String("alias") == "") || cCtx.String("car") == "") {
-		return errors.New("must specify either --fav OR --account and --car  OR --alias and --car parameters")
+	if cCtx.NArg() == 0 {
+		return errors.New("must specify a command to run")
 	}
 
-	// Set the favorite region as the default region if a favorite is used
+	// fan-out runs take favorites by pattern or a car on many accounts
+	if isFanOut(cCtx) {
+		if cCtx.String("favorite") != "" || cCtx.String("account") != "" || cCtx.String("alias") != "" {
+			return errors.New("--favorites, --accounts, and --project can not be combined with --fav, --account, or --alias")
+		}
+		if cCtx.String("favorites") != "" {
+			if len(cCtx.StringSlice("accounts")) > 0 || cCtx.String("project") != "" {
+				return errors.New("--favorites can not be combined with --accounts or --project")
+			}
+			return nil
+		}
+		if cCtx.String("car") == "" {
+			return errors.New("must specify --car parameter when using --accounts or --project")
+		}
+		return nil
+	}
+
+	// validate that either a favorite is used or both account/alias and car are provided
 	favName := cCtx.String("favorite")
+	if favName == "" {
+		if (cCtx.String("account") == "" && cCtx.String("alias") == "") || cCtx.String("car") == "" {
+			return errors.New("must specify either --fav OR --account and --car  OR --alias and --car parameters")
+		}
+		return nil
+	}
+
 	_, fMap := helper.MapFavs(c.config.Favorites)
-	var fav string
-	if fMap[favName] != (structs.Favorite{}) {
-		fav = favName
-	} else {
+	if fMap[favName] == (structs.Favorite{}) {
 		return errors.New("can't find favorite")
 	}
-	favorite := fMap[fav]
-	if favorite.Region != "" {
-		c.config.Kion.DefaultRegion = favorite.Region
-	}
 
 	return nil
 }
@@ -0,0 +1,564 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kionsoftware/kion-cli/lib/kion"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+//                                                                            //
+//  AWS Config                                                                //
+//                                                                            //
+////////////////////////////////////////////////////////////////////////////////
+
+// managedMarker is the comment that marks sections of AWS config files as
+// written by the Kion CLI. Sections without it are never changed.
+const managedMarker = "managed by kion-cli"
+
+// AWSProfile is a profile the Kion CLI manages in the AWS config file.
+type AWSProfile struct {
+	Name              string
+	CredentialProcess string
+	Region            string
+}
+
+// AWSConfigChange describes what syncing did, or would do, to a profile. The
+// action is one of "add", "update", "remove", or "skip" for profiles that
+// exist but were not written by the Kion CLI.
+type AWSConfigChange struct {
+	Profile string
+	Action  string
+}
+
+// AWSCredentialsPath returns the path of the users AWS credentials file,
+// honoring AWS_SHARED_CREDENTIALS_FILE like the AWS CLI does.
+func AWSCredentialsPath() (string, error) {
+	if path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".aws", "credentials"), nil
+}
+
+// AWSConfigPath returns the path of the users AWS config file, honoring
+// AWS_CONFIG_FILE like the AWS CLI does.
+func AWSConfigPath() (string, error) {
+	if path := os.Getenv("AWS_CONFIG_FILE"); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".aws", "config"), nil
+}
+
+// profileField matches the fields of a profile name template, ie {car}.
+var profileField = regexp.MustCompile(`\{([a-z_]*)\}`)
+
+// RenderProfileName fills a profile name template such as "{alias}-{car}"
+// with the given fields. Whitespace is replaced with dashes and brackets are
+// dropped so the result is usable as an ini section name.
+func RenderProfileName(template string, fields map[string]string) (string, error) {
+	var unknown []string
+	name := profileField.ReplaceAllStringFunc(template, func(match string) string {
+		field := match[1 : len(match)-1]
+		value, found := fields[field]
+		if !found {
+			unknown = append(unknown, match)
+		}
+		return value
+	})
+	if len(unknown) > 0 {
+		known := make([]string, 0, len(fields))
+		for field := range fields {
+			known = append(known, "{"+field+"}")
+		}
+		sort.Strings(known)
+		return "", fmt.Errorf("unknown profile name field %v, expected %v", strings.Join(unknown, ", "), strings.Join(known, ", "))
+	}
+
+	name = strings.Join(strings.Fields(strings.NewReplacer("[", "", "]", "").Replace(name)), "-")
+	if name == "" {
+		return "", fmt.Errorf("profile name template %q rendered an empty name", template)
+	}
+	return name, nil
+}
+
+// configSectionName returns the config file section name for a profile.
+func configSectionName(profile string) string {
+	if profile == "default" {
+		return profile
+	}
+	return "profile " + profile
+}
+
+// SyncAWSProfiles writes the given profiles into the contents of an AWS config
+// file as sections marked as managed by the Kion CLI, updating ones it wrote
+// before. With prune, managed profiles that are no longer wanted are removed.
+// Sections the Kion CLI did not write are left untouched and reported as
+// skipped.
+func SyncAWSProfiles(data []byte, profiles []AWSProfile, prune bool) ([]byte, []AWSConfigChange, error) {
+	file := parseINI(data)
+	changes, err := syncAWSProfiles(file, profiles, prune)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file.bytes(), changes, nil
+}
+
+// syncAWSProfiles syncs profiles into a parsed AWS config file.
+func syncAWSProfiles(file *iniFile, profiles []AWSProfile, prune bool) ([]AWSConfigChange, error) {
+	// write the wanted profiles in a stable order
+	profiles = append([]AWSProfile(nil), profiles...)
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+
+	var changes []AWSConfigChange
+	wanted := make(map[string]bool)
+	for _, profile := range profiles {
+		name := configSectionName(profile.Name)
+		if wanted[name] {
+			return nil, fmt.Errorf("more than one profile is named %v, adjust the profile name template", profile.Name)
+		}
+		wanted[name] = true
+
+		section := file.section(name)
+		action := "update"
+		switch {
+		case section == nil:
+			section = file.addSection(name)
+			section.lines = []string{"# " + managedMarker}
+			action = "add"
+		case !section.hasComment(managedMarker):
+			changes = append(changes, AWSConfigChange{profile.Name, "skip"})
+			continue
+		}
+
+		before := strings.Join(section.lines, "\n")
+		section.set("credential_process", profile.CredentialProcess)
+		if profile.Region != "" {
+			section.set("region", profile.Region)
+		} else {
+			section.unset("region")
+		}
+		if action == "add" || strings.Join(section.lines, "\n") != before {
+			changes = append(changes, AWSConfigChange{profile.Name, action})
+		}
+	}
+
+	// drop managed profiles that are no longer wanted
+	if prune {
+		for _, section := range append([]*iniSection(nil), file.sections...) {
+			if wanted[section.name] || !section.hasComment(managedMarker) {
+				continue
+			}
+			file.removeSection(section.name)
+			changes = append(changes, AWSConfigChange{strings.TrimPrefix(section.name, "profile "), "remove"})
+		}
+	}
+
+	return changes, nil
+}
+
+// SyncAWSConfig syncs managed profiles into the AWS config file at the given
+// path as described by SyncAWSProfiles. It returns the changes and a line
+// diff of the file, which is left as is on a dry run.
+func SyncAWSConfig(path string, profiles []AWSProfile, prune bool, dryRun bool) ([]AWSConfigChange, []string, error) {
+	var changes []AWSConfigChange
+	before, after, err := editINIFile(path, dryRun, func(file *iniFile) error {
+		var err error
+		changes, err = syncAWSProfiles(file, profiles, prune)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return changes, LineDiff(string(before), string(after)), nil
+}
+
+// DefaultAWSProfileTemplate names saved credentials profiles after the
+// account number and IAM role, ie "121212121212_OrgAdmin".
+const DefaultAWSProfileTemplate = "{account}_{role}"
+
+// AWSProfileName renders the name of the profile credentials for a CAR are
+// saved under, the default template if none is given. The template fields are
+// account, alias, account_name, car, role, and region.
+func AWSProfileName(template string, car kion.CAR, region string) (string, error) {
+	if template == "" {
+		template = DefaultAWSProfileTemplate
+	}
+	return RenderProfileName(template, map[string]string{
+		"account":      car.AccountNumber,
+		"alias":        car.AccountAlias,
+		"account_name": car.AccountName,
+		"car":          car.Name,
+		"role":         car.AwsIamRoleName,
+		"region":       region,
+	})
+}
+
+// SaveAWSCreds saves the short term access keys for AWS auth to the users AWS
+// credentials file under a profile named by the template, see
+// RenderProfileName, creating or updating it while leaving other profiles,
+// keys, and comments as they were. With saveRegion the region is also set on
+// the profile in the AWS config file unless that profile was written by hand.
+// Profiles it creates are marked as managed along with when their keys
+// expire so PruneAWSCreds can clean them up later.
+func SaveAWSCreds(stak kion.STAK, car kion.CAR, template string, region string, saveRegion bool) error {
+	profile, err := AWSProfileName(template, car, region)
+	if err != nil {
+		return err
+	}
+
+	credsPath, err := AWSCredentialsPath()
+	if err != nil {
+		return err
+	}
+	_, _, err = editINIFile(credsPath, false, func(file *iniFile) error {
+		section := file.section(profile)
+		if section == nil {
+			section = file.addSection(profile)
+			markManaged(section, stak.Expiration)
+		} else if section.hasComment(managedMarker) {
+			markManaged(section, stak.Expiration)
+		}
+		section.set("aws_access_key_id", stak.AccessKey)
+		section.set("aws_secret_access_key", stak.SecretAccessKey)
+		section.set("aws_session_token", stak.SessionToken)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println("Credentials updated in the file:", credsPath)
+
+	if saveRegion && region != "" {
+		configPath, err := AWSConfigPath()
+		if err != nil {
+			return err
+		}
+		handWritten := false
+		_, _, err = editINIFile(configPath, false, func(file *iniFile) error {
+			name := configSectionName(profile)
+			section := file.section(name)
+			switch {
+			case section == nil:
+				section = file.addSection(name)
+				section.lines = []string{"# " + managedMarker}
+			case !section.hasComment(managedMarker):
+				handWritten = true
+				return nil
+			}
+			section.set("region", region)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if handWritten {
+			fmt.Printf("Region not saved, the profile in %v was not written by the Kion CLI\n", configPath)
+		} else {
+			fmt.Printf("Region %v saved in the file: %v\n", region, configPath)
+		}
+	}
+
+	fmt.Printf("You can reference this profile using this flag: --profile %v\n", profile)
+	fmt.Printf("Example command: aws s3 ls --profile %v\n", profile)
+
+	return nil
+}
+
+// expiresMarker follows the managed marker on credentials profiles to record
+// when their keys expire, ie "# managed by kion-cli, expires 2024-01-01T12:00:00Z".
+const expiresMarker = ", expires "
+
+// markManaged marks a section as managed by the Kion CLI, recording when its
+// keys expire if known.
+func markManaged(section *iniSection, expiration time.Time) {
+	line := "# " + managedMarker
+	if !expiration.IsZero() {
+		line += expiresMarker + expiration.UTC().Format(time.RFC3339)
+	}
+	if i, found := section.comment(managedMarker); found {
+		section.lines[i] = line
+		return
+	}
+	section.lines = slices.Insert(section.lines, 0, line)
+}
+
+// managedExpiration returns the expiration recorded on a managed section,
+// false if it has none.
+func managedExpiration(section *iniSection) (time.Time, bool) {
+	i, found := section.comment(managedMarker)
+	if !found {
+		return time.Time{}, false
+	}
+	_, value, found := strings.Cut(section.lines[i], expiresMarker)
+	if !found {
+		return time.Time{}, false
+	}
+	expiration, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return expiration, true
+}
+
+// AWSCredsProfile is a credentials profile managed by the Kion CLI and what
+// pruning did with it, "remove" or "keep".
+type AWSCredsProfile struct {
+	Profile    string
+	Expiration time.Time
+	Action     string
+}
+
+// PruneAWSProfiles removes credentials profiles managed by the Kion CLI from
+// the contents of an AWS credentials file, those with keys that expired before
+// now or with all every one of them. Profiles without an expiration are only
+// removed with all and profiles the Kion CLI did not create are never touched.
+func PruneAWSProfiles(data []byte, all bool, now time.Time) ([]byte, []AWSCredsProfile) {
+	file := parseINI(data)
+	profiles := pruneAWSProfiles(file, all, now)
+	return file.bytes(), profiles
+}
+
+// pruneAWSProfiles prunes managed profiles from a parsed credentials file.
+func pruneAWSProfiles(file *iniFile, all bool, now time.Time) []AWSCredsProfile {
+	var profiles []AWSCredsProfile
+	for _, section := range slices.Clone(file.sections) {
+		if !section.hasComment(managedMarker) {
+			continue
+		}
+		expiration, found := managedExpiration(section)
+		profile := AWSCredsProfile{Profile: section.name, Expiration: expiration, Action: "keep"}
+		if all || (found && !expiration.After(now)) {
+			file.removeSection(section.name)
+			profile.Action = "remove"
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles
+}
+
+// PruneAWSCreds prunes managed profiles from the AWS credentials file at the
+// given path as described by PruneAWSProfiles. It returns the managed
+// profiles and a line diff of the file, which is left as is on a dry run.
+func PruneAWSCreds(path string, all bool, dryRun bool) ([]AWSCredsProfile, []string, error) {
+	var profiles []AWSCredsProfile
+	before, after, err := editINIFile(path, dryRun, func(file *iniFile) error {
+		profiles = pruneAWSProfiles(file, all, time.Now())
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return profiles, LineDiff(string(before), string(after)), nil
+}
+
+// lockTimeout is how long to wait for another Kion CLI process to finish
+// writing an AWS file.
+const lockTimeout = 10 * time.Second
+
+// errLocked is returned by tryLock when another process holds the lock.
+var errLocked = errors.New("file is locked")
+
+// lockFile takes an exclusive lock on a file shared with other Kion CLI
+// processes, ie concurrent `kion stak --save` runs, through an OS lock on a
+// lock file beside it. The OS releases the lock if the process dies, so the
+// lock file is left in place. A symlink is resolved first so every path to a
+// file shares one lock beside the file writeFileAtomic replaces. The returned
+// func releases the lock.
+func lockFile(path string) (func(), error) {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	lock := path + ".lock"
+	f, err := os.OpenFile(lock, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		err := tryLock(f)
+		if err == nil {
+			return func() {
+				unlock(f)
+				f.Close()
+			}, nil
+		}
+		if !errors.Is(err, errLocked) {
+			f.Close()
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("timed out waiting for another kion process to release %v", lock)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// editINIFile locks an ini file and applies the edit to its parsed contents,
+// then atomically replaces the file if it changed. A dry run only reads the
+// file, leaving no lock file or directories behind. It returns the contents
+// before and after the edit.
+func editINIFile(path string, dryRun bool, edit func(*iniFile) error) ([]byte, []byte, error) {
+	if !dryRun {
+		unlock, err := lockFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer unlock()
+	}
+
+	before, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+
+	file := parseINI(before)
+	if err := edit(file); err != nil {
+		return nil, nil, err
+	}
+	after := file.bytes()
+
+	if dryRun || string(after) == string(before) {
+		return before, after, nil
+	}
+	return before, after, writeFileAtomic(path, after, 0600)
+}
+
+// writeFileAtomic replaces a file by writing a temporary file beside it and
+// renaming it into place, so readers never see a partial file. An existing
+// file keeps its permissions, and a symlink is followed so the file it points
+// to is replaced rather than the link.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// LineDiff returns the lines that differ between two texts, prefixed with "-"
+// or "+", along with up to two unchanged lines of context prefixed with a
+// space. Separate hunks are split by a "@@" line. It is empty if the texts
+// are the same.
+func LineDiff(before string, after string) []string {
+	a := strings.Split(strings.TrimSuffix(before, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(after, "\n"), "\n")
+	if before == "" {
+		a = nil
+	}
+	if after == "" {
+		b = nil
+	}
+
+	// longest common subsequence lengths of every pair of suffixes
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	// walk the table into a full listing of kept, removed, and added lines
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, " "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+
+	// keep only changes and their context
+	const context = 2
+	keep := make([]bool, len(lines))
+	for n, line := range lines {
+		if line[0] == ' ' {
+			continue
+		}
+		for k := max(n-context, 0); k <= min(n+context, len(lines)-1); k++ {
+			keep[k] = true
+		}
+	}
+	var diff []string
+	for n, line := range lines {
+		if !keep[n] {
+			continue
+		}
+		if len(diff) > 0 && !keep[n-1] {
+			diff = append(diff, "@@")
+		}
+		diff = append(diff, line)
+	}
+
+	return diff
+}
@@ -0,0 +1,393 @@
+package helper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kionsoftware/kion-cli/lib/kion"
+)
+
+func TestSyncAWSProfiles(t *testing.T) {
+	existing := `# my settings
+[default]
+region = us-east-1
+
+# hand written, must be left alone
+[profile sandbox]
+credential_process = other-tool
+region = eu-west-1
+
+[profile old]
+# managed by kion-cli
+credential_process = kion stak --credential-process --account 1 --car Old
+
+[profile prod]
+# managed by kion-cli
+credential_process = kion stak --credential-process --account 2 --car Admin
+region = us-east-1
+output = json
+`
+	profiles := []AWSProfile{
+		{Name: "prod", CredentialProcess: "kion stak --credential-process --account 2 --car Admin", Region: "us-west-2"},
+		{Name: "sandbox", CredentialProcess: "kion stak --credential-process --account 3 --car Admin"},
+		{Name: "dev", CredentialProcess: "kion stak --credential-process --account 4 --car Dev"},
+	}
+
+	got, changes, err := SyncAWSProfiles([]byte(existing), profiles, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# my settings
+[default]
+region = us-east-1
+
+# hand written, must be left alone
+[profile sandbox]
+credential_process = other-tool
+region = eu-west-1
+
+[profile prod]
+# managed by kion-cli
+credential_process = kion stak --credential-process --account 2 --car Admin
+region = us-west-2
+output = json
+
+[profile dev]
+# managed by kion-cli
+credential_process = kion stak --credential-process --account 4 --car Dev
+`
+	if string(got) != want {
+		t.Errorf("\ngot:\n%v\nwanted:\n%v", string(got), want)
+	}
+
+	wantChanges := []AWSConfigChange{
+		{"dev", "add"},
+		{"prod", "update"},
+		{"sandbox", "skip"},
+		{"old", "remove"},
+	}
+	if !reflect.DeepEqual(changes, wantChanges) {
+		t.Errorf("got changes %v, wanted %v", changes, wantChanges)
+	}
+
+	// a second sync is a no-op
+	again, changes, err := SyncAWSProfiles(got, profiles, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(got) {
+		t.Errorf("second sync changed the file:\n%v", string(again))
+	}
+	if !reflect.DeepEqual(changes, []AWSConfigChange{{"sandbox", "skip"}}) {
+		t.Errorf("second sync reported changes: %v", changes)
+	}
+
+	// without prune stale profiles are kept
+	kept, _, err := SyncAWSProfiles([]byte(existing), profiles, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(kept), "[profile old]") {
+		t.Errorf("profile was pruned without prune")
+	}
+
+	// duplicate names are refused
+	if _, _, err := SyncAWSProfiles(nil, append(profiles, profiles[0]), false); err == nil {
+		t.Errorf("expected an error for duplicate profile names")
+	}
+}
+
+func TestRenderProfileName(t *testing.T) {
+	fields := map[string]string{"alias": "Prod Account", "car": "Admin [RO]", "account": "111122223333"}
+
+	got, err := RenderProfileName("{alias}-{car}", fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Prod-Account-Admin-RO" {
+		t.Errorf("got %q", got)
+	}
+
+	if _, err := RenderProfileName("{account}-{role}", fields); err == nil || !strings.Contains(err.Error(), "{role}") {
+		t.Errorf("expected an unknown field error, got %v", err)
+	}
+	if _, err := RenderProfileName("{region}", map[string]string{"region": ""}); err == nil {
+		t.Errorf("expected an error for an empty name")
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\n"
+	after := "a\nB\nc\nd\ne\nf\ng\nh\ni\n"
+
+	want := []string{" a", "-b", "+B", " c", " d", "@@", " g", " h", "+i"}
+	if got := LineDiff(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+
+	if got := LineDiff(before, before); len(got) != 0 {
+		t.Errorf("expected no diff, got %q", got)
+	}
+	if got := LineDiff("", "a\n"); !reflect.DeepEqual(got, []string{"+a"}) {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestSaveAWSCreds(t *testing.T) {
+	dir := t.TempDir()
+	credsPath := filepath.Join(dir, "credentials")
+	configPath := filepath.Join(dir, "config")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credsPath)
+	t.Setenv("AWS_CONFIG_FILE", configPath)
+
+	existing := `# personal keys
+[default]
+aws_access_key_id=AKIADEFAULT
+aws_secret_access_key=default
+
+[other_111122223333_Admin]
+aws_access_key_id=AKIAOTHER
+
+[111122223333_Admin]
+aws_access_key_id=AKIAOLD
+aws_secret_access_key=old
+aws_session_token=old
+region=us-east-1
+; kept
+`
+	if err := os.WriteFile(credsPath, []byte(existing), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	expiration := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	stak := kion.STAK{AccessKey: "ASIANEW", SecretAccessKey: "new", SessionToken: "token", Expiration: expiration}
+	car := kion.CAR{Name: "Admin", AccountNumber: "111122223333", AccountAlias: "sandbox", AwsIamRoleName: "Admin"}
+
+	if err := SaveAWSCreds(stak, car, "", "us-west-2", false); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(existing, `aws_access_key_id=AKIAOLD
+aws_secret_access_key=old
+aws_session_token=old`, `aws_access_key_id=ASIANEW
+aws_secret_access_key=new
+aws_session_token=token`, 1)
+	got, _ := os.ReadFile(credsPath)
+	if string(got) != want {
+		t.Errorf("\ngot:\n%v\nwanted:\n%v", string(got), want)
+	}
+	if _, err := os.Stat(configPath); !os.IsNotExist(err) {
+		t.Errorf("config file written without saving the region")
+	}
+
+	// a templated name adds a new managed profile and its region
+	if err := SaveAWSCreds(stak, car, "{alias}-{car}", "us-west-2", true); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = os.ReadFile(credsPath)
+	if !strings.HasSuffix(string(got), "; kept\n\n[sandbox-Admin]\n# managed by kion-cli, expires 2024-01-01T12:00:00Z\naws_access_key_id = ASIANEW\naws_secret_access_key = new\naws_session_token = token\n") {
+		t.Errorf("profile not added:\n%v", string(got))
+	}
+	config, _ := os.ReadFile(configPath)
+	if string(config) != "[profile sandbox-Admin]\n# managed by kion-cli\nregion = us-west-2\n" {
+		t.Errorf("region not saved:\n%v", string(config))
+	}
+
+	// hand written config profiles are left alone
+	handWritten := "[profile sandbox-Admin]\nregion = eu-west-1\n"
+	if err := os.WriteFile(configPath, []byte(handWritten), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveAWSCreds(stak, car, "{alias}-{car}", "us-west-2", true); err != nil {
+		t.Fatal(err)
+	}
+	config, _ = os.ReadFile(configPath)
+	if string(config) != handWritten {
+		t.Errorf("hand written profile changed:\n%v", string(config))
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+
+	unlock, err := lockFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a second lock waits until the first is released
+	var released atomic.Bool
+	acquired := make(chan error, 1)
+	go func() {
+		unlockAgain, err := lockFile(path)
+		if err == nil {
+			if !released.Load() {
+				err = errors.New("lock taken while still held")
+			}
+			unlockAgain()
+		}
+		acquired <- err
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	released.Store(true)
+	unlock()
+	if err := <-acquired; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockFileSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "dotfiles", "credentials")
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(target, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "credentials")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	unlock, err := lockFile(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	// the lock sits beside the file the link points to
+	if _, err := os.Stat(target + ".lock"); err != nil {
+		t.Errorf("lock not beside the target: %v", err)
+	}
+	if _, err := os.Lstat(link + ".lock"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("lock created beside the link: %v", err)
+	}
+}
+
+func TestEditINIFileDryRun(t *testing.T) {
+	dir := t.TempDir()
+	addProfile := func(file *iniFile) error {
+		file.addSection("profile dev")
+		return nil
+	}
+
+	// a dry run creates neither the directory nor a lock file
+	missing := filepath.Join(dir, "missing", "config")
+	before, after, err := editINIFile(missing, true, addProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(before) != 0 || !strings.Contains(string(after), "[profile dev]") {
+		t.Errorf("got before %q after %q", before, after)
+	}
+	if _, err := os.Stat(filepath.Dir(missing)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("dry run created the directory: %v", err)
+	}
+
+	existing := filepath.Join(dir, "config")
+	if err := os.WriteFile(existing, []byte("[default]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := editINIFile(existing, true, addProfile); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "[default]\n" {
+		t.Errorf("dry run wrote the file: %q", data)
+	}
+	if _, err := os.Stat(existing + ".lock"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("dry run created a lock file: %v", err)
+	}
+}
+
+func TestPruneAWSProfiles(t *testing.T) {
+	existing := `[default]
+aws_access_key_id = AKIADEFAULT
+
+[111122223333_Admin]
+# managed by kion-cli, expires 2024-01-01T12:00:00Z
+aws_access_key_id = ASIAEXPIRED
+
+[hand-written]
+# managed by kion-cli is mentioned here but not as the marker
+aws_access_key_id = AKIAHAND
+
+[444455556666_ReadOnly]
+# managed by kion-cli, expires 2024-01-01T14:00:00Z
+aws_access_key_id = ASIAVALID
+
+[555566667777_Admin]
+# managed by kion-cli
+aws_access_key_id = ASIAUNKNOWN
+`
+	now := time.Date(2024, 1, 1, 13, 0, 0, 0, time.UTC)
+
+	got, profiles := PruneAWSProfiles([]byte(existing), false, now)
+	want := `[default]
+aws_access_key_id = AKIADEFAULT
+
+[hand-written]
+# managed by kion-cli is mentioned here but not as the marker
+aws_access_key_id = AKIAHAND
+
+[444455556666_ReadOnly]
+# managed by kion-cli, expires 2024-01-01T14:00:00Z
+aws_access_key_id = ASIAVALID
+
+[555566667777_Admin]
+# managed by kion-cli
+aws_access_key_id = ASIAUNKNOWN
+`
+	if string(got) != want {
+		t.Errorf("\ngot:\n%v\nwanted:\n%v", string(got), want)
+	}
+	var actions []string
+	for _, profile := range profiles {
+		actions = append(actions, profile.Profile+" "+profile.Action)
+	}
+	wantActions := []string{"111122223333_Admin remove", "444455556666_ReadOnly keep", "555566667777_Admin keep"}
+	if !reflect.DeepEqual(actions, wantActions) {
+		t.Errorf("got %q, wanted %q", actions, wantActions)
+	}
+
+	// all removes every managed profile, leaving no trailing blank line
+	got, _ = PruneAWSProfiles([]byte(existing), true, now)
+	want = "[default]\naws_access_key_id = AKIADEFAULT\n\n[hand-written]\n# managed by kion-cli is mentioned here but not as the marker\naws_access_key_id = AKIAHAND\n"
+	if string(got) != want {
+		t.Errorf("\ngot:\n%v\nwanted:\n%v", string(got), want)
+	}
+}
+
+func TestWriteFileAtomicSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "dotfiles", "credentials")
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(target, []byte("old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "credentials")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	if err := writeFileAtomic(link, []byte("new\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the link is kept and the file it points to is replaced
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("symlink was replaced: %v", err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "new\n" {
+		t.Errorf("target not updated, got %q", data)
+	}
+}
@@ -1,14 +1,17 @@
 package helper
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/kionsoftware/kion-cli/lib/defaults"
 	"github.com/kionsoftware/kion-cli/lib/structs"
 
 	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
 )
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -49,6 +52,16 @@ func LoadConfig(filename string, config *structs.Configuration) error {
 	return nil
 }
 
+// ResolveTLSPaths makes the relative TLS file paths of a Kion configuration
+// absolute, resolving them from the directory holding the configuration file.
+func ResolveTLSPaths(config *structs.Kion, configPath string) {
+	for _, path := range []*string{&config.CABundle, &config.ClientCert, &config.ClientKey} {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(filepath.Dir(configPath), *path)
+		}
+	}
+}
+
 // SaveConfig saves the entirety of the current config to the users config file.
 func SaveConfig(filename string, config structs.Configuration) error {
 	// marshal to yaml
@@ -60,3 +73,92 @@ func SaveConfig(filename string, config structs.Configuration) error {
 	// write it out
 	return os.WriteFile(filename, bytes, 0644)
 }
+
+// SaveFavorites replaces the favorites of the named profile, or the top level
+// favorites if no profile is named, in the users config file. Only the
+// favorites are rewritten, comments, key order, and other settings are kept,
+// and the file is replaced atomically.
+func SaveFavorites(filename string, profileName string, favorites []structs.Favorite) error {
+	data, err := os.ReadFile(filename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	// edit the parsed document so everything but the favorites survives
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", filename, err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yamlv3.Node{Kind: yamlv3.DocumentNode, Content: []*yamlv3.Node{{Kind: yamlv3.MappingNode}}}
+	}
+	parent := doc.Content[0]
+	if parent.Kind != yamlv3.MappingNode {
+		return fmt.Errorf("failed to parse config file %s: expected a mapping", filename)
+	}
+	if profileName != "" {
+		parent = mappingValue(mappingValue(parent, "profiles"), profileName)
+		if parent == nil {
+			return fmt.Errorf("profile not found: %s", profileName)
+		}
+	}
+
+	var value yamlv3.Node
+	if err := value.Encode(favorites); err != nil {
+		return err
+	}
+	setMappingValue(parent, "favorites", &value, len(favorites) == 0)
+
+	var out bytes.Buffer
+	encoder := yamlv3.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filename, out.Bytes(), 0600)
+}
+
+// mappingValue returns the mapping under a key of a mapping node, nil if
+// there is none. An empty value is turned into an empty mapping.
+func mappingValue(node *yamlv3.Node, key string) *yamlv3.Node {
+	if node == nil || node.Kind != yamlv3.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != key {
+			continue
+		}
+		value := node.Content[i+1]
+		if value.Kind == yamlv3.ScalarNode && value.Tag == "!!null" {
+			*value = yamlv3.Node{Kind: yamlv3.MappingNode}
+		}
+		if value.Kind != yamlv3.MappingNode {
+			return nil
+		}
+		return value
+	}
+	return nil
+}
+
+// setMappingValue sets the value of a key in a mapping node, adding the key
+// if missing, or removes the key if remove is set.
+func setMappingValue(node *yamlv3.Node, key string, value *yamlv3.Node, remove bool) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != key {
+			continue
+		}
+		if remove {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+		} else {
+			node.Content[i+1] = value
+		}
+		return
+	}
+	if !remove {
+		node.Content = append(node.Content, &yamlv3.Node{Kind: yamlv3.ScalarNode, Value: key}, value)
+	}
+}
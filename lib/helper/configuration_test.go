@@ -0,0 +1,158 @@
+package helper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kionsoftware/kion-cli/lib/structs"
+)
+
+func TestSaveFavorites(t *testing.T) {
+	existing := `# my kion settings
+kion:
+  url: https://kion.example.com # production
+  unknown_key: kept
+favorites:
+  - name: old
+    account: "111111111111"
+    cloud_access_role: Admin
+profiles:
+  work:
+    kion:
+      url: https://work.example.com
+  empty:
+`
+	dev := []structs.Favorite{{Name: "dev", Account: "222222222222", CAR: "ReadOnly", AccessType: "cli", DescriptiveName: "dev [local]"}}
+
+	tests := []struct {
+		name      string
+		profile   string
+		favorites []structs.Favorite
+		want      string
+		wantErr   bool
+	}{
+		{
+			"TopLevel",
+			"",
+			dev,
+			`# my kion settings
+kion:
+  url: https://kion.example.com # production
+  unknown_key: kept
+favorites:
+  - name: dev
+    account: "222222222222"
+    cloud_access_role: ReadOnly
+    access_type: cli
+profiles:
+  work:
+    kion:
+      url: https://work.example.com
+  empty:
+`,
+			false,
+		},
+		{
+			"Profile",
+			"work",
+			dev,
+			`# my kion settings
+kion:
+  url: https://kion.example.com # production
+  unknown_key: kept
+favorites:
+  - name: old
+    account: "111111111111"
+    cloud_access_role: Admin
+profiles:
+  work:
+    kion:
+      url: https://work.example.com
+    favorites:
+      - name: dev
+        account: "222222222222"
+        cloud_access_role: ReadOnly
+        access_type: cli
+  empty:
+`,
+			false,
+		},
+		{
+			"EmptyProfile",
+			"empty",
+			dev,
+			`# my kion settings
+kion:
+  url: https://kion.example.com # production
+  unknown_key: kept
+favorites:
+  - name: old
+    account: "111111111111"
+    cloud_access_role: Admin
+profiles:
+  work:
+    kion:
+      url: https://work.example.com
+  empty:
+    favorites:
+      - name: dev
+        account: "222222222222"
+        cloud_access_role: ReadOnly
+        access_type: cli
+`,
+			false,
+		},
+		{
+			"RemoveAll",
+			"",
+			nil,
+			`# my kion settings
+kion:
+  url: https://kion.example.com # production
+  unknown_key: kept
+profiles:
+  work:
+    kion:
+      url: https://work.example.com
+  empty:
+`,
+			false,
+		},
+		{"MissingProfile", "nope", dev, existing, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".kion.yml")
+			if err := os.WriteFile(path, []byte(existing), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			err := SaveFavorites(path, test.profile, test.favorites)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, wanted error: %v", err, test.wantErr)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("\ngot:\n%v\nwanted:\n%v", string(got), test.want)
+			}
+		})
+	}
+
+	// a missing file is created
+	path := filepath.Join(t.TempDir(), ".kion.yml")
+	if err := SaveFavorites(path, "", dev); err != nil {
+		t.Fatal(err)
+	}
+	var config structs.Configuration
+	if err := LoadConfig(path, &config); err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Favorites) != 1 || config.Favorites[0].Name != "dev" {
+		t.Errorf("unexpected favorites: %v", config.Favorites)
+	}
+}
@@ -0,0 +1,137 @@
+package helper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kionsoftware/kion-cli/lib/kion"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+//                                                                            //
+//  Fan-Out                                                                   //
+//                                                                            //
+////////////////////////////////////////////////////////////////////////////////
+
+// PrefixWriter prefixes every line written to it before passing it on, so
+// output from commands running side by side can be told apart. Writers that
+// share a mutex never interleave within a line.
+type PrefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+// NewPrefixWriter returns a PrefixWriter that writes lines to w behind the
+// given prefix, holding mu for each line.
+func NewPrefixWriter(w io.Writer, mu *sync.Mutex, prefix string) *PrefixWriter {
+	return &PrefixWriter{mu: mu, w: w, prefix: prefix}
+}
+
+// Write writes every complete line in p, holding back a trailing partial line
+// until it is completed or flushed.
+func (p *PrefixWriter) Write(data []byte) (int, error) {
+	p.buf = append(p.buf, data...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := p.writeLine(p.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[i+1:]
+	}
+	return len(data), nil
+}
+
+// Flush writes any held back partial line, ending it with a newline.
+func (p *PrefixWriter) Flush() error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+	line := append(p.buf, '\n')
+	p.buf = nil
+	return p.writeLine(line)
+}
+
+// writeLine writes a single line behind the prefix.
+func (p *PrefixWriter) writeLine(line []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := fmt.Fprintf(p.w, "%s%s", p.prefix, line)
+	return err
+}
+
+// RunCommandWithOutput runs a command with short term access keys set in its
+// environment, in place of any inherited AWS credentials, and waits for it to
+// finish. Unlike RunCommand the Kion CLI keeps running, so the command must
+// be an executable on the PATH and gets no stdin. The exit code of the
+// command is returned, an error only if it could not be run.
+func RunCommandWithOutput(ctx context.Context, stak kion.STAK, region string, stdout io.Writer, stderr io.Writer, name string, args ...string) (int, error) {
+	binary, err := exec.LookPath(name)
+	if err != nil {
+		return -1, err
+	}
+
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Env = append(withoutAWSCredentials(os.Environ()), STAKEnv(stak)...)
+	cmd.Env = append(cmd.Env, ExpirationEnv(stak.Expiration))
+	if region != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("AWS_REGION=%s", region))
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
+
+// RunResult is the outcome of running a command against one target of a
+// fan-out run.
+type RunResult struct {
+	Target   string
+	Account  string
+	CAR      string
+	ExitCode int
+	Err      error
+	Duration time.Duration
+}
+
+// Failed reports whether the command failed or could not be run.
+func (r RunResult) Failed() bool {
+	return r.Err != nil || r.ExitCode != 0
+}
+
+// PrintRunSummary prints a table of fan-out results, one row per target.
+func PrintRunSummary(w io.Writer, results []RunResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tACCOUNT\tCAR\tSTATUS\tDURATION")
+	for _, result := range results {
+		status := "ok"
+		switch {
+		case result.Err != nil:
+			status = "error: " + result.Err.Error()
+		case result.ExitCode != 0:
+			status = fmt.Sprintf("exit %d", result.ExitCode)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", result.Target, result.Account, result.CAR, status, result.Duration.Round(time.Millisecond))
+	}
+	return tw.Flush()
+}
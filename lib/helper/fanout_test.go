@@ -0,0 +1,88 @@
+package helper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kionsoftware/kion-cli/lib/kion"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	var output bytes.Buffer
+	var mu sync.Mutex
+	w := NewPrefixWriter(&output, &mu, "prod | ")
+
+	w.Write([]byte("one\ntw"))
+	w.Write([]byte("o\nthree"))
+	if output.String() != "prod | one\nprod | two\n" {
+		t.Errorf("partial line was written early:\n%v", output.String())
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := "prod | one\nprod | two\nprod | three\n"
+	if output.String() != want {
+		t.Errorf("\ngot:\n%v\nwanted:\n%v", output.String(), want)
+	}
+}
+
+func TestRunCommandWithOutput(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	t.Setenv("AWS_PROFILE", "inherited")
+
+	stak := kion.STAK{AccessKey: "ASIATEST", Expiration: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	var stdout, stderr bytes.Buffer
+	code, err := RunCommandWithOutput(context.Background(), stak, "us-east-1", &stdout, &stderr,
+		"sh", "-c", `echo "$AWS_ACCESS_KEY_ID $AWS_REGION $KION_STAK_EXPIRATION${AWS_PROFILE}"; echo oops >&2; exit 3`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 3 {
+		t.Errorf("got exit code %d, wanted 3", code)
+	}
+	if stdout.String() != "ASIATEST us-east-1 2024-01-01T12:00:00Z\n" {
+		t.Errorf("unexpected stdout: %q", stdout.String())
+	}
+	if stderr.String() != "oops\n" {
+		t.Errorf("unexpected stderr: %q", stderr.String())
+	}
+
+	if _, err := RunCommandWithOutput(context.Background(), stak, "", &stdout, &stderr, "kion-no-such-command"); err == nil {
+		t.Errorf("expected an error for a missing command")
+	}
+}
+
+func TestPrintRunSummary(t *testing.T) {
+	results := []RunResult{
+		{Target: "sandbox", Account: "111122223333", CAR: "Admin", Duration: 1500 * time.Millisecond},
+		{Target: "prod", Account: "444455556666", CAR: "Admin", ExitCode: 254, Duration: time.Second},
+		{Target: "dev", Account: "777788889999", CAR: "Admin", Err: errors.New("forbidden")},
+	}
+
+	var output bytes.Buffer
+	if err := PrintRunSummary(&output, results); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"TARGET   ACCOUNT       CAR    STATUS            DURATION",
+		"sandbox  111122223333  Admin  ok                1.5s",
+		"prod     444455556666  Admin  exit 254          1s",
+		"dev      777788889999  Admin  error: forbidden  0s",
+	}
+	if got := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("\ngot:\n%v\nwanted:\n%v", output.String(), strings.Join(want, "\n"))
+	}
+
+	if !results[1].Failed() || !results[2].Failed() || results[0].Failed() {
+		t.Errorf("unexpected Failed results")
+	}
+}
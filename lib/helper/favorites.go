@@ -0,0 +1,238 @@
+package helper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/kionsoftware/kion-cli/lib/kion"
+	"github.com/kionsoftware/kion-cli/lib/structs"
+	"gopkg.in/yaml.v2"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+//                                                                            //
+//  Favorite Files                                                            //
+//                                                                            //
+////////////////////////////////////////////////////////////////////////////////
+
+// FavoriteOutputs are the output formats supported by MarshalFavorites.
+var FavoriteOutputs = []string{"yaml", "json"}
+
+// favoriteRecord is a favorite as shared by export and import, with the keys
+// of the configuration file in both YAML and JSON.
+type favoriteRecord struct {
+	Name                 string `yaml:"name" json:"name"`
+	Account              string `yaml:"account" json:"account"`
+	CAR                  string `yaml:"cloud_access_role" json:"cloud_access_role"`
+	AccessType           string `yaml:"access_type,omitempty" json:"access_type,omitempty"`
+	Region               string `yaml:"region,omitempty" json:"region,omitempty"`
+	Service              string `yaml:"service,omitempty" json:"service,omitempty"`
+	FirefoxContainerName string `yaml:"firefox_container_name,omitempty" json:"firefox_container_name,omitempty"`
+	CloudServiceProvider string `yaml:"cloud_service_provider,omitempty" json:"cloud_service_provider,omitempty"`
+}
+
+// favoritesDocument is the top level of a favorites file, shaped like the
+// configuration file so exports can be pasted into it.
+type favoritesDocument struct {
+	Favorites []favoriteRecord `yaml:"favorites" json:"favorites"`
+}
+
+// MarshalFavorites renders favorites as a YAML or JSON favorites file.
+// Favorites without an alias are left out as they can't be imported.
+func MarshalFavorites(favorites []structs.Favorite, output string) ([]byte, error) {
+	document := favoritesDocument{Favorites: []favoriteRecord{}}
+	for _, f := range favorites {
+		if f.Unaliased {
+			continue
+		}
+		document.Favorites = append(document.Favorites, favoriteRecord{
+			Name:                 f.Name,
+			Account:              f.Account,
+			CAR:                  f.CAR,
+			AccessType:           f.AccessType,
+			Region:               f.Region,
+			Service:              f.Service,
+			FirefoxContainerName: f.FirefoxContainerName,
+			CloudServiceProvider: f.CloudServiceProvider,
+		})
+	}
+
+	switch output {
+	case "yaml":
+		return yaml.Marshal(document)
+	case "json":
+		data, err := json.MarshalIndent(document, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	default:
+		return nil, fmt.Errorf("unsupported output format: %v, expected %v", output, strings.Join(FavoriteOutputs, ", "))
+	}
+}
+
+// ParseFavorites parses a YAML or JSON favorites file, either a document with
+// a favorites key as written by MarshalFavorites or a bare list. Every
+// favorite needs a unique name, an account, and a cloud access role.
+func ParseFavorites(data []byte) ([]structs.Favorite, error) {
+	var document favoritesDocument
+	trimmed := bytes.TrimSpace(data)
+	var err error
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		err = json.Unmarshal(trimmed, &document.Favorites)
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		err = json.Unmarshal(trimmed, &document)
+	case bytes.HasPrefix(trimmed, []byte("-")):
+		err = yaml.Unmarshal(trimmed, &document.Favorites)
+	default:
+		err = yaml.Unmarshal(trimmed, &document)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse favorites: %w", err)
+	}
+	if len(document.Favorites) == 0 {
+		return nil, errors.New("no favorites found")
+	}
+
+	var favorites []structs.Favorite
+	names := make(map[string]bool)
+	for i, r := range document.Favorites {
+		if r.Name == "" || r.Account == "" || r.CAR == "" {
+			return nil, fmt.Errorf("favorite %d is missing a name, account, or cloud_access_role", i+1)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("favorite %v is listed more than once", r.Name)
+		}
+		names[r.Name] = true
+		if r.AccessType != "" && r.AccessType != "cli" && r.AccessType != "web" {
+			return nil, fmt.Errorf("favorite %v has an unsupported access_type: %v, expected cli or web", r.Name, r.AccessType)
+		}
+		favorites = append(favorites, structs.Favorite{
+			Name:                 r.Name,
+			Account:              r.Account,
+			CAR:                  r.CAR,
+			AccessType:           r.AccessType,
+			Region:               r.Region,
+			Service:              r.Service,
+			FirefoxContainerName: r.FirefoxContainerName,
+			CloudServiceProvider: r.CloudServiceProvider,
+		})
+	}
+	return favorites, nil
+}
+
+// maxFavoritesSize caps how much of a downloaded favorites file is read.
+const maxFavoritesSize = 1 << 20
+
+// ReadFavorites reads and parses a favorites file from a path, an http or
+// https URL downloaded with the given client, or stdin when the source is "-".
+func ReadFavorites(ctx context.Context, client *http.Client, source string) ([]structs.Favorite, error) {
+	var data []byte
+	var err error
+	switch {
+	case source == "-":
+		data, err = io.ReadAll(os.Stdin)
+	case strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://"):
+		data, err = fetchFavorites(ctx, client, source)
+	default:
+		data, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseFavorites(data)
+}
+
+// fetchFavorites downloads a favorites file of up to maxFavoritesSize.
+func fetchFavorites(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download favorites from %v: %v", url, resp.Status)
+	}
+
+	// read one byte past the cap to tell a full file from a cut off one
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFavoritesSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFavoritesSize {
+		return nil, fmt.Errorf("favorites from %v are larger than %d bytes", url, maxFavoritesSize)
+	}
+	return data, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+//                                                                            //
+//  Upstream Favorites                                                        //
+//                                                                            //
+////////////////////////////////////////////////////////////////////////////////
+
+// UpstreamAddTarget checks that a favorite can be added to Kion alongside the
+// favorites already there. Kion keeps one favorite per account, cloud access
+// role, and access type, so an unaliased favorite for the same target is
+// returned to be given the new alias, and an aliased one is an error.
+func UpstreamAddTarget(existing []structs.Favorite, favorite structs.Favorite) (*structs.Favorite, error) {
+	index := slices.IndexFunc(existing, func(f structs.Favorite) bool { return SameFavoriteTarget(f, favorite) })
+	if index < 0 {
+		return nil, nil
+	}
+	if !existing[index].Unaliased {
+		return nil, fmt.Errorf("favorite %v in Kion already uses this cloud access role, rename it with `kion favorite rename --upstream %v %v`", existing[index].Name, existing[index].Name, favorite.Name)
+	}
+	return &existing[index], nil
+}
+
+// FindUpstreamFavorite returns the aliased favorite in Kion with the given
+// name.
+func FindUpstreamFavorite(favorites []structs.Favorite, name string) (structs.Favorite, error) {
+	index := FindFavorite(favorites, name)
+	if index < 0 {
+		return structs.Favorite{}, fmt.Errorf("no favorite named %q in Kion", name)
+	}
+	return favorites[index], nil
+}
+
+// AddUpstreamFavorite creates a favorite in Kion, which sets the alias of an
+// unaliased favorite for the same target.
+func AddUpstreamFavorite(ctx context.Context, client *kion.Client, favorite structs.Favorite) error {
+	favorite.AccessType = kion.ConvertAccessType(favorite.AccessType)
+	_, err := client.CreateFavorite(ctx, favorite)
+	return err
+}
+
+// RenameUpstreamFavorite renames a favorite in Kion. The API has no update, so
+// the favorite is deleted and created again under the new name, and restored
+// if that fails.
+func RenameUpstreamFavorite(ctx context.Context, client *kion.Client, favorite structs.Favorite, newName string) error {
+	err := client.DeleteFavorite(ctx, favorite.Name)
+	if err != nil {
+		return err
+	}
+	renamed := favorite
+	renamed.Name = newName
+	err = AddUpstreamFavorite(ctx, client, renamed)
+	if err != nil {
+		if restoreErr := AddUpstreamFavorite(ctx, client, favorite); restoreErr != nil {
+			return fmt.Errorf("%w, and restoring favorite %v also failed: %v", err, favorite.Name, restoreErr)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,332 @@
+package helper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kionsoftware/kion-cli/lib/kion"
+	"github.com/kionsoftware/kion-cli/lib/structs"
+)
+
+func TestMarshalFavorites(t *testing.T) {
+	favorites := []structs.Favorite{
+		{Name: "sandbox", Account: "111122223333", CAR: "Admin", AccessType: "web", Service: "ec2", DescriptiveName: "sandbox [local]"},
+		{Name: "[unaliased]", Account: "444455556666", CAR: "ReadOnly", AccessType: "cli", Unaliased: true},
+		{Name: "prod", Account: "444455556666", CAR: "ReadOnly", Region: "us-west-2"},
+	}
+
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{
+			"yaml",
+			"favorites:\n- name: sandbox\n  account: \"111122223333\"\n  cloud_access_role: Admin\n  access_type: web\n  service: ec2\n" +
+				"- name: prod\n  account: \"444455556666\"\n  cloud_access_role: ReadOnly\n  region: us-west-2\n",
+		},
+		{
+			"json",
+			"{\n  \"favorites\": [\n" +
+				"    {\n      \"name\": \"sandbox\",\n      \"account\": \"111122223333\",\n      \"cloud_access_role\": \"Admin\",\n      \"access_type\": \"web\",\n      \"service\": \"ec2\"\n    },\n" +
+				"    {\n      \"name\": \"prod\",\n      \"account\": \"444455556666\",\n      \"cloud_access_role\": \"ReadOnly\",\n      \"region\": \"us-west-2\"\n    }\n  ]\n}\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.output, func(t *testing.T) {
+			data, err := MarshalFavorites(favorites, test.output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != test.want {
+				t.Errorf("\ngot:\n%v\nwanted:\n%v", string(data), test.want)
+			}
+
+			// exports import back without the unaliased favorite
+			parsed, err := ParseFavorites(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []structs.Favorite{
+				{Name: "sandbox", Account: "111122223333", CAR: "Admin", AccessType: "web", Service: "ec2"},
+				{Name: "prod", Account: "444455556666", CAR: "ReadOnly", Region: "us-west-2"},
+			}
+			if !reflect.DeepEqual(parsed, want) {
+				t.Errorf("\ngot:\n  %v\nwanted:\n  %v", parsed, want)
+			}
+		})
+	}
+
+	if _, err := MarshalFavorites(favorites, "csv"); err == nil {
+		t.Errorf("expected an error for an unsupported output")
+	}
+}
+
+func TestParseFavorites(t *testing.T) {
+	want := []structs.Favorite{{Name: "prod", Account: "444455556666", CAR: "ReadOnly", AccessType: "cli"}}
+
+	tests := []struct {
+		name    string
+		data    string
+		want    []structs.Favorite
+		wantErr bool
+	}{
+		{"YAMLList", "- name: prod\n  account: \"444455556666\"\n  cloud_access_role: ReadOnly\n  access_type: cli\n", want, false},
+		{"JSONList", `[{"name": "prod", "account": "444455556666", "cloud_access_role": "ReadOnly", "access_type": "cli"}]`, want, false},
+		{"Empty", "favorites: []\n", nil, true},
+		{"MissingCAR", "favorites:\n- name: prod\n  account: \"444455556666\"\n", nil, true},
+		{"Duplicate", "- {name: prod, account: \"444455556666\", cloud_access_role: ReadOnly}\n- {name: prod, account: \"111122223333\", cloud_access_role: Admin}\n", nil, true},
+		{"BadAccessType", "favorites:\n- name: prod\n  account: \"444455556666\"\n  cloud_access_role: ReadOnly\n  access_type: console\n", nil, true},
+		{"NotYAML", "favorites: [\n", nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseFavorites([]byte(test.data))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, wanted error: %v", err, test.wantErr)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("\ngot:\n  %v\nwanted:\n  %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReadFavorites(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/favorites.yml":
+			w.Write([]byte("favorites:\n- name: prod\n  account: \"444455556666\"\n  cloud_access_role: ReadOnly\n"))
+		case "/huge.yml":
+			w.Write(bytes.Repeat([]byte("#"), maxFavoritesSize+1))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	favorites, err := ReadFavorites(context.Background(), server.Client(), server.URL+"/favorites.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(favorites) != 1 || favorites[0].Name != "prod" {
+		t.Errorf("unexpected favorites: %v", favorites)
+	}
+
+	if _, err := ReadFavorites(context.Background(), server.Client(), server.URL+"/missing.yml"); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+	if _, err := ReadFavorites(context.Background(), server.Client(), server.URL+"/huge.yml"); err == nil {
+		t.Errorf("expected an error for an oversized file")
+	}
+}
+
+func TestUpstreamAddTarget(t *testing.T) {
+	existing := []structs.Favorite{
+		{Name: "prod", Account: "222222222222", CAR: "ReadOnly", AccessType: "cli"},
+		{Name: "[unaliased]", Account: "333333333333", CAR: "Admin", AccessType: "web", Unaliased: true},
+	}
+
+	tests := []struct {
+		name      string
+		favorite  structs.Favorite
+		unaliased bool
+		wantErr   bool
+	}{
+		{"New", structs.Favorite{Name: "dev", Account: "444444444444", CAR: "Admin", AccessType: "cli"}, false, false},
+		{"OtherAccessType", structs.Favorite{Name: "prod-web", Account: "222222222222", CAR: "ReadOnly", AccessType: "web"}, false, false},
+		{"Unaliased", structs.Favorite{Name: "sandbox", Account: "333333333333", CAR: "Admin", AccessType: "web"}, true, false},
+		{"TargetConflict", structs.Favorite{Name: "prod-ro", Account: "222222222222", CAR: "ReadOnly"}, false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := UpstreamAddTarget(existing, test.favorite)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, wanted error: %v", err, test.wantErr)
+			}
+			if (got != nil) != test.unaliased {
+				t.Errorf("got unaliased favorite %v, wanted one: %v", got, test.unaliased)
+			}
+		})
+	}
+}
+
+func TestFindUpstreamFavorite(t *testing.T) {
+	favorites := []structs.Favorite{
+		{Name: "prod", Account: "222222222222", CAR: "ReadOnly"},
+		{Name: "[unaliased]", Account: "333333333333", CAR: "Admin", Unaliased: true},
+	}
+
+	if f, err := FindUpstreamFavorite(favorites, "prod"); err != nil || f.Account != "222222222222" {
+		t.Errorf("got %v %v, wanted prod", f, err)
+	}
+	for _, name := range []string{"[unaliased]", "missing"} {
+		if _, err := FindUpstreamFavorite(favorites, name); err == nil {
+			t.Errorf("expected an error finding %q", name)
+		}
+	}
+}
+
+// fakeFavorites serves the Kion favorites API from memory. Creating a
+// favorite named in failCreate fails, as does every create if it holds "*".
+type fakeFavorites struct {
+	mu         sync.Mutex
+	favorites  []map[string]string
+	failCreate []string
+}
+
+func (f *fakeFavorites) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var body map[string]string
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	reply := func(status int, data any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]any{"status": status, "data": data})
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		reply(200, f.favorites)
+	case http.MethodPost:
+		if slices.Contains(f.failCreate, body["alias_name"]) || slices.Contains(f.failCreate, "*") {
+			reply(500, nil)
+			return
+		}
+		// kion gives an unaliased favorite for the same target the alias
+		for _, fav := range f.favorites {
+			if fav["alias_name"] == "" && fav["account_number"] == body["account_number"] &&
+				fav["cloud_access_role_name"] == body["cloud_access_role_name"] && fav["access_type"] == body["access_type"] {
+				fav["alias_name"] = body["alias_name"]
+				reply(201, fav)
+				return
+			}
+		}
+		f.favorites = append(f.favorites, body)
+		reply(201, body)
+	case http.MethodDelete:
+		index := slices.IndexFunc(f.favorites, func(fav map[string]string) bool { return fav["alias_name"] == body["alias_name"] })
+		if index < 0 {
+			reply(404, nil)
+			return
+		}
+		f.favorites = slices.Delete(f.favorites, index, index+1)
+		reply(200, nil)
+	}
+}
+
+func TestUpstreamFavorites(t *testing.T) {
+	prod := map[string]string{"alias_name": "prod", "account_number": "222222222222", "cloud_access_role_name": "ReadOnly", "access_type": "short_term_key_access"}
+	unaliased := map[string]string{"alias_name": "", "account_number": "333333333333", "cloud_access_role_name": "Admin", "access_type": "console_access"}
+
+	tests := []struct {
+		name       string
+		failCreate []string
+		change     func(ctx context.Context, client *kion.Client, favorites []structs.Favorite) error
+		wantErr    string
+		want       []string
+	}{
+		{
+			"AddSetsUnaliasedAlias",
+			nil,
+			func(ctx context.Context, client *kion.Client, favorites []structs.Favorite) error {
+				return AddUpstreamFavorite(ctx, client, structs.Favorite{Name: "sandbox", Account: "333333333333", CAR: "Admin", AccessType: "web"})
+			},
+			"",
+			[]string{"prod 222222222222 ReadOnly cli", "sandbox 333333333333 Admin web"},
+		},
+		{
+			"Remove",
+			nil,
+			func(ctx context.Context, client *kion.Client, favorites []structs.Favorite) error {
+				f, err := FindUpstreamFavorite(favorites, "prod")
+				if err != nil {
+					return err
+				}
+				return client.DeleteFavorite(ctx, f.Name)
+			},
+			"",
+			[]string{"[unaliased] 333333333333 Admin web"},
+		},
+		{
+			"Rename",
+			nil,
+			func(ctx context.Context, client *kion.Client, favorites []structs.Favorite) error {
+				f, _ := FindUpstreamFavorite(favorites, "prod")
+				return RenameUpstreamFavorite(ctx, client, f, "prod-ro")
+			},
+			"",
+			[]string{"[unaliased] 333333333333 Admin web", "prod-ro 222222222222 ReadOnly cli"},
+		},
+		{
+			"RenameRestoresOnFailure",
+			[]string{"prod-ro"},
+			func(ctx context.Context, client *kion.Client, favorites []structs.Favorite) error {
+				f, _ := FindUpstreamFavorite(favorites, "prod")
+				return RenameUpstreamFavorite(ctx, client, f, "prod-ro")
+			},
+			"failed to create favorite",
+			[]string{"[unaliased] 333333333333 Admin web", "prod 222222222222 ReadOnly cli"},
+		},
+		{
+			"RenameRestoreFails",
+			[]string{"*"},
+			func(ctx context.Context, client *kion.Client, favorites []structs.Favorite) error {
+				f, _ := FindUpstreamFavorite(favorites, "prod")
+				return RenameUpstreamFavorite(ctx, client, f, "prod-ro")
+			},
+			"restoring favorite prod also failed",
+			[]string{"[unaliased] 333333333333 Admin web"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := &fakeFavorites{favorites: []map[string]string{maps.Clone(prod), maps.Clone(unaliased)}, failCreate: test.failCreate}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+			client := kion.NewClient(server.URL, "app_test")
+			client.Retry.MaxAttempts = 1
+
+			ctx := context.Background()
+			favorites, err := client.GetAPIFavorites(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = test.change(ctx, client, favorites)
+			if test.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+				t.Fatalf("got error %v, wanted %q", err, test.wantErr)
+			}
+
+			favorites, err = client.GetAPIFavorites(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range favorites {
+				got = append(got, fmt.Sprintf("%v %v %v %v", f.Name, f.Account, f.CAR, f.AccessType))
+			}
+			slices.Sort(got)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("\ngot:\n  %v\nwanted:\n  %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package helper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kionsoftware/kion-cli/lib/kion"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+//                                                                            //
+//  STAK Formats                                                              //
+//                                                                            //
+////////////////////////////////////////////////////////////////////////////////
+
+// STAKDetails holds a STAK along with what it was issued for, everything a
+// STAK format may render. The CAR may only be partially filled when keys came
+// from the cache.
+type STAKDetails struct {
+	STAK   kion.STAK
+	CAR    kion.CAR
+	Region string
+	Shell  Shell
+}
+
+// STAKFormat renders STAK details for a consumer of printed keys.
+type STAKFormat func(w io.Writer, details STAKDetails) error
+
+// DefaultSTAKFormat is the format printed keys use when none is requested,
+// exports for the users shell.
+const DefaultSTAKFormat = "export"
+
+// stakFormats is the registry of STAK formats by name.
+var stakFormats = map[string]STAKFormat{
+	"export":             printExportFormat,
+	"json":               printJSONFormat,
+	"dotenv":             printDotenvFormat,
+	"docker":             printDockerEnvFormat,
+	"github":             printGitHubEnvFormat,
+	"credential-process": printCredentialProcessFormat,
+}
+
+// RegisterSTAKFormat adds a STAK format to the registry, replacing any
+// existing format of the same name.
+func RegisterSTAKFormat(name string, format STAKFormat) {
+	stakFormats[name] = format
+}
+
+// STAKFormatNames returns the names of all registered STAK formats, sorted.
+func STAKFormatNames() []string {
+	names := make([]string, 0, len(stakFormats))
+	for name := range stakFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupSTAKFormat returns the named STAK format, the default if the name is
+// empty.
+func LookupSTAKFormat(name string) (STAKFormat, error) {
+	if name == "" {
+		name = DefaultSTAKFormat
+	}
+	format, found := stakFormats[name]
+	if !found {
+		return nil, fmt.Errorf("unsupported format: %v, expected one of %v", name, strings.Join(STAKFormatNames(), ", "))
+	}
+	return format, nil
+}
+
+// PrintSTAKFormat prints STAK details in the named format.
+func PrintSTAKFormat(w io.Writer, name string, details STAKDetails) error {
+	format, err := LookupSTAKFormat(name)
+	if err != nil {
+		return err
+	}
+	return format(w, details)
+}
+
+// stakVars returns the environment variables for a STAK as name and value
+// pairs, with the region first if set.
+func stakVars(details STAKDetails) [][2]string {
+	var vars [][2]string
+	if details.Region != "" {
+		vars = append(vars, [2]string{"AWS_REGION", details.Region})
+	}
+	return append(vars,
+		[2]string{"AWS_ACCESS_KEY_ID", details.STAK.AccessKey},
+		[2]string{"AWS_SECRET_ACCESS_KEY", details.STAK.SecretAccessKey},
+		[2]string{"AWS_SESSION_TOKEN", details.STAK.SessionToken},
+	)
+}
+
+// printExportFormat prints exports for the users shell.
+func printExportFormat(w io.Writer, details STAKDetails) error {
+	return PrintSTAK(w, details.STAK, details.Region, details.Shell)
+}
+
+// stakJSON is the json STAK format, the keys along with when they expire and
+// what they were issued for.
+type stakJSON struct {
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	SessionToken    string `json:"session_token"`
+	Expiration      string `json:"expiration,omitempty"`
+	Region          string `json:"region,omitempty"`
+	AccountNumber   string `json:"account_number,omitempty"`
+	AccountName     string `json:"account_name,omitempty"`
+	AccountAlias    string `json:"account_alias,omitempty"`
+	CAR             string `json:"cloud_access_role,omitempty"`
+	AwsIamRoleName  string `json:"aws_iam_role_name,omitempty"`
+}
+
+// printJSONFormat prints the keys and their metadata as a json object.
+func printJSONFormat(w io.Writer, details STAKDetails) error {
+	out := stakJSON{
+		AccessKeyID:     details.STAK.AccessKey,
+		SecretAccessKey: details.STAK.SecretAccessKey,
+		SessionToken:    details.STAK.SessionToken,
+		Region:          details.Region,
+		AccountNumber:   details.CAR.AccountNumber,
+		AccountName:     details.CAR.AccountName,
+		AccountAlias:    details.CAR.AccountAlias,
+		CAR:             details.CAR.Name,
+		AwsIamRoleName:  details.CAR.AwsIamRoleName,
+	}
+	if !details.STAK.Expiration.IsZero() {
+		out.Expiration = details.STAK.Expiration.UTC().Format(time.RFC3339)
+	}
+
+	jsonData, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, string(jsonData))
+
+	return nil
+}
+
+// printDotenvFormat prints a .env file, double quoting values that need it.
+func printDotenvFormat(w io.Writer, details STAKDetails) error {
+	for _, v := range stakVars(details) {
+		value := v[1]
+		if strings.ContainsAny(value, " \t\n\"'#$\\`") {
+			value = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "$", `\$`, "`", "\\`").Replace(value) + `"`
+		}
+		fmt.Fprintf(w, "%s=%s\n", v[0], value)
+	}
+	return nil
+}
+
+// printDockerEnvFormat prints a file for docker run --env-file, which takes
+// every value literally and has no way to quote a newline.
+func printDockerEnvFormat(w io.Writer, details STAKDetails) error {
+	vars := stakVars(details)
+	for _, v := range vars {
+		if strings.ContainsAny(v[1], "\r\n") {
+			return fmt.Errorf("%v contains a newline, which docker env files can't represent", v[0])
+		}
+	}
+	for _, v := range vars {
+		fmt.Fprintf(w, "%s=%s\n", v[0], v[1])
+	}
+	return nil
+}
+
+// printGitHubEnvFormat prints lines to append to the $GITHUB_ENV file of a
+// GitHub Actions job, using a random delimiter for any multiline value.
+func printGitHubEnvFormat(w io.Writer, details STAKDetails) error {
+	for _, v := range stakVars(details) {
+		if !strings.ContainsAny(v[1], "\r\n") {
+			fmt.Fprintf(w, "%s=%s\n", v[0], v[1])
+			continue
+		}
+		delimiter, err := NewAuthToken()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s<<ghadelimiter_%s\n%s\nghadelimiter_%s\n", v[0], delimiter, v[1], delimiter)
+	}
+	return nil
+}
+
+// printCredentialProcessFormat prints the keys for an AWS credential process.
+func printCredentialProcessFormat(w io.Writer, details STAKDetails) error {
+	return PrintCredentialProcess(w, details.STAK)
+}
@@ -0,0 +1,125 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kionsoftware/kion-cli/lib/kion"
+)
+
+func TestPrintSTAKFormat(t *testing.T) {
+	details := STAKDetails{
+		STAK: kion.STAK{
+			AccessKey:       "ASIAABCDEFGHIJ1K23LM",
+			SecretAccessKey: "aBCDeFg1hijkl2m3NOPqr4StUvWxY56z7abc8DEf",
+			SessionToken:    "AbcDEF+gh/IJ=",
+			Expiration:      time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		},
+		CAR: kion.CAR{
+			Name:          "Admin",
+			AccountNumber: "111122223333",
+			AccountName:   "Sandbox",
+		},
+		Region: "us-east-1",
+		Shell:  ShellBash,
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			"",
+			"export AWS_REGION=us-east-1\nexport AWS_ACCESS_KEY_ID=ASIAABCDEFGHIJ1K23LM\nexport AWS_SECRET_ACCESS_KEY=aBCDeFg1hijkl2m3NOPqr4StUvWxY56z7abc8DEf\nexport AWS_SESSION_TOKEN=AbcDEF+gh/IJ=\n",
+		},
+		{
+			"dotenv",
+			"AWS_REGION=us-east-1\nAWS_ACCESS_KEY_ID=ASIAABCDEFGHIJ1K23LM\nAWS_SECRET_ACCESS_KEY=aBCDeFg1hijkl2m3NOPqr4StUvWxY56z7abc8DEf\nAWS_SESSION_TOKEN=AbcDEF+gh/IJ=\n",
+		},
+		{
+			"docker",
+			"AWS_REGION=us-east-1\nAWS_ACCESS_KEY_ID=ASIAABCDEFGHIJ1K23LM\nAWS_SECRET_ACCESS_KEY=aBCDeFg1hijkl2m3NOPqr4StUvWxY56z7abc8DEf\nAWS_SESSION_TOKEN=AbcDEF+gh/IJ=\n",
+		},
+		{
+			"github",
+			"AWS_REGION=us-east-1\nAWS_ACCESS_KEY_ID=ASIAABCDEFGHIJ1K23LM\nAWS_SECRET_ACCESS_KEY=aBCDeFg1hijkl2m3NOPqr4StUvWxY56z7abc8DEf\nAWS_SESSION_TOKEN=AbcDEF+gh/IJ=\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			var output bytes.Buffer
+			if err := PrintSTAKFormat(&output, test.format, details); err != nil {
+				t.Fatal(err)
+			}
+			if output.String() != test.want {
+				t.Errorf("\ngot:\n  %v\nwanted:\n  %v", output.String(), test.want)
+			}
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		var output bytes.Buffer
+		if err := PrintSTAKFormat(&output, "json", details); err != nil {
+			t.Fatal(err)
+		}
+		var got map[string]string
+		if err := json.Unmarshal(output.Bytes(), &got); err != nil {
+			t.Fatalf("invalid json: %v", err)
+		}
+		want := map[string]string{
+			"access_key_id":     "ASIAABCDEFGHIJ1K23LM",
+			"secret_access_key": "aBCDeFg1hijkl2m3NOPqr4StUvWxY56z7abc8DEf",
+			"session_token":     "AbcDEF+gh/IJ=",
+			"expiration":        "2024-01-01T12:00:00Z",
+			"region":            "us-east-1",
+			"account_number":    "111122223333",
+			"account_name":      "Sandbox",
+			"cloud_access_role": "Admin",
+		}
+		for key, value := range want {
+			if got[key] != value {
+				t.Errorf("%v: got %q, wanted %q", key, got[key], value)
+			}
+		}
+		if _, found := got["account_alias"]; found {
+			t.Errorf("empty account_alias should be omitted")
+		}
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		err := PrintSTAKFormat(&bytes.Buffer{}, "yaml", details)
+		if err == nil || !strings.Contains(err.Error(), "dotenv") {
+			t.Errorf("expected an error listing the formats, got %v", err)
+		}
+	})
+}
+
+func TestPrintSTAKFormatMultiline(t *testing.T) {
+	details := STAKDetails{STAK: kion.STAK{SessionToken: "line one\nline two"}}
+
+	var output bytes.Buffer
+	if err := PrintSTAKFormat(&output, "dotenv", details); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output.String(), `AWS_SESSION_TOKEN="line one\nline two"`) {
+		t.Errorf("dotenv did not quote the value:\n%v", output.String())
+	}
+
+	if err := PrintSTAKFormat(&bytes.Buffer{}, "docker", details); err == nil {
+		t.Errorf("docker should refuse a multiline value")
+	}
+
+	output.Reset()
+	if err := PrintSTAKFormat(&output, "github", details); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	last := lines[len(lines)-4:]
+	if !strings.HasPrefix(last[0], "AWS_SESSION_TOKEN<<ghadelimiter_") || last[1] != "line one" || last[2] != "line two" || last[3] != strings.TrimPrefix(last[0], "AWS_SESSION_TOKEN<<") {
+		t.Errorf("github did not use a delimiter:\n%v", output.String())
+	}
+}
@@ -0,0 +1,231 @@
+package helper
+
+import (
+	"slices"
+	"strings"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+//                                                                            //
+//  INI Files                                                                 //
+//                                                                            //
+////////////////////////////////////////////////////////////////////////////////
+
+// iniFile is an AWS style ini file parsed just enough to edit sections and
+// keys while leaving every other line, comments and unknown keys included, as
+// it was.
+type iniFile struct {
+	preamble  []string
+	sections  []*iniSection
+	linebreak string
+}
+
+// iniSection is a section of an ini file, its name as written between the
+// brackets and the raw lines that follow the header.
+type iniSection struct {
+	name  string
+	lines []string
+}
+
+// parseINI parses the contents of an ini file. Anything that isn't a section
+// header is kept verbatim with the section it appears in.
+func parseINI(data []byte) *iniFile {
+	file := &iniFile{linebreak: "\n"}
+	text := string(data)
+	if strings.Contains(text, "\r\n") {
+		file.linebreak = "\r\n"
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+	}
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return file
+	}
+
+	var current *iniSection
+	for _, line := range strings.Split(text, "\n") {
+		if name, ok := iniHeader(line); ok {
+			current = &iniSection{name: name}
+			file.sections = append(file.sections, current)
+			continue
+		}
+		if current == nil {
+			file.preamble = append(file.preamble, line)
+		} else {
+			current.lines = append(current.lines, line)
+		}
+	}
+
+	return file
+}
+
+// iniHeader returns the section name if the line is a section header.
+func iniHeader(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "[") || !strings.HasSuffix(trimmed, "]") {
+		return "", false
+	}
+	return strings.TrimSpace(trimmed[1 : len(trimmed)-1]), true
+}
+
+// iniKey returns the key set by a line, false for comments, blank lines, and
+// the indented continuation lines of nested values.
+func iniKey(line string) (string, bool) {
+	if line == "" || line[0] == ' ' || line[0] == '\t' {
+		return "", false
+	}
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || trimmed[0] == '#' || trimmed[0] == ';' {
+		return "", false
+	}
+	key, _, found := strings.Cut(trimmed, "=")
+	if !found {
+		return "", false
+	}
+	return strings.TrimSpace(key), true
+}
+
+// bytes renders the file with a trailing line break.
+func (f *iniFile) bytes() []byte {
+	lines := slices.Clone(f.preamble)
+	for _, section := range f.sections {
+		lines = append(lines, "["+section.name+"]")
+		lines = append(lines, section.lines...)
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, f.linebreak) + f.linebreak)
+}
+
+// section returns the section with the exact given name, nil if missing.
+func (f *iniFile) section(name string) *iniSection {
+	for _, section := range f.sections {
+		if section.name == name {
+			return section
+		}
+	}
+	return nil
+}
+
+// addSection appends a new empty section, separated from what comes before
+// it by a blank line.
+func (f *iniFile) addSection(name string) *iniSection {
+	previous := &f.preamble
+	if len(f.sections) > 0 {
+		previous = &f.sections[len(f.sections)-1].lines
+	}
+	if n := len(*previous); n > 0 && strings.TrimSpace((*previous)[n-1]) != "" {
+		*previous = append(*previous, "")
+	}
+
+	section := &iniSection{name: name}
+	f.sections = append(f.sections, section)
+	return section
+}
+
+// removeSection removes the section with the exact given name. Removing the
+// last section also drops the blank lines that separated it from the rest.
+func (f *iniFile) removeSection(name string) {
+	for i, section := range f.sections {
+		if section.name != name {
+			continue
+		}
+		f.sections = append(f.sections[:i], f.sections[i+1:]...)
+		if i == len(f.sections) {
+			previous := &f.preamble
+			if i > 0 {
+				previous = &f.sections[i-1].lines
+			}
+			for n := len(*previous); n > 0 && strings.TrimSpace((*previous)[n-1]) == ""; n-- {
+				*previous = (*previous)[:n-1]
+			}
+		}
+		return
+	}
+}
+
+// get returns the value of a key in the section.
+func (s *iniSection) get(key string) (string, bool) {
+	for _, line := range s.lines {
+		if k, ok := iniKey(line); ok && k == key {
+			_, value, _ := strings.Cut(line, "=")
+			return strings.TrimSpace(value), true
+		}
+	}
+	return "", false
+}
+
+// set sets a key in the section, replacing the line and any nested value
+// lines of an existing key or else adding it after the last non-blank line.
+func (s *iniSection) set(key string, value string) {
+	if start, end, found := s.find(key); found {
+		// keep the spacing of the existing line, ie key=value
+		separator := " = "
+		if !strings.Contains(s.lines[start], " =") {
+			separator = "="
+		}
+		s.lines = slices.Replace(s.lines, start, end, key+separator+value)
+		return
+	}
+	s.lines = slices.Insert(s.lines, s.contentEnd(), key+" = "+value)
+}
+
+// unset removes a key and any nested value lines from the section.
+func (s *iniSection) unset(key string) {
+	if start, end, found := s.find(key); found {
+		s.lines = slices.Delete(s.lines, start, end)
+	}
+}
+
+// find returns the range of lines holding a key and its nested value lines.
+func (s *iniSection) find(key string) (int, int, bool) {
+	for i, line := range s.lines {
+		if k, ok := iniKey(line); ok && k == key {
+			end := i + 1
+			for end < len(s.lines) && isContinuation(s.lines[end]) {
+				end++
+			}
+			return i, end, true
+		}
+	}
+	return 0, 0, false
+}
+
+// hasComment reports whether the section has a comment line of the given
+// text, see comment.
+func (s *iniSection) hasComment(text string) bool {
+	_, found := s.comment(text)
+	return found
+}
+
+// comment returns the index of the first comment line in the section whose
+// text after the comment character is the given text, optionally followed by
+// a comma and details, ie "managed by kion-cli, expires ...".
+func (s *iniSection) comment(text string) (int, bool) {
+	for i, line := range s.lines {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "#") && !strings.HasPrefix(trimmed, ";") {
+			continue
+		}
+		rest, found := strings.CutPrefix(strings.TrimSpace(trimmed[1:]), text)
+		if found && (rest == "" || rest[0] == ',') {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// contentEnd returns the index just past the last non-blank line, where new
+// keys are added so trailing blank lines stay between sections.
+func (s *iniSection) contentEnd() int {
+	end := len(s.lines)
+	for end > 0 && strings.TrimSpace(s.lines[end-1]) == "" {
+		end--
+	}
+	return end
+}
+
+// isContinuation reports whether a line is part of a nested value.
+func isContinuation(line string) bool {
+	return line != "" && (line[0] == ' ' || line[0] == '\t') && strings.TrimSpace(line) != ""
+}
@@ -0,0 +1,71 @@
+package helper
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v2"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+//                                                                            //
+//  Listings                                                                  //
+//                                                                            //
+////////////////////////////////////////////////////////////////////////////////
+
+// ListOutputs are the output formats supported by PrintList.
+var ListOutputs = []string{"table", "json", "csv", "yaml"}
+
+// ValidateListOutput returns an error if the output format is not supported
+// by PrintList.
+func ValidateListOutput(output string) error {
+	if !slices.Contains(ListOutputs, output) {
+		return fmt.Errorf("unsupported output format: %v, expected %v", output, strings.Join(ListOutputs, ", "))
+	}
+	return nil
+}
+
+// PrintList prints a listing in the given output format. Tables and CSV are
+// built from the headers and rows, JSON and YAML from the records, which
+// should be a slice of structs tagged for both.
+func PrintList(w io.Writer, output string, headers []string, rows [][]string, records any) error {
+	switch output {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(headers, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write(headers); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+		return cw.Error()
+	case "json":
+		jsonData, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(jsonData))
+		return nil
+	case "yaml":
+		yamlData, err := yaml.Marshal(records)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(yamlData)
+		return err
+	default:
+		return ValidateListOutput(output)
+	}
+}
@@ -0,0 +1,54 @@
+package helper
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrintList(t *testing.T) {
+	type record struct {
+		Name  string `json:"name" yaml:"name"`
+		Alias string `json:"alias,omitempty" yaml:"alias,omitempty"`
+	}
+	records := []record{{Name: "Sandbox", Alias: "sandbox"}, {Name: "Prod, East"}}
+	headers := []string{"NAME", "ALIAS"}
+	rows := [][]string{{"Sandbox", "sandbox"}, {"Prod, East", ""}}
+
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{
+			"table",
+			"NAME        ALIAS\nSandbox     sandbox\nProd, East  \n",
+		},
+		{
+			"csv",
+			"NAME,ALIAS\nSandbox,sandbox\n\"Prod, East\",\n",
+		},
+		{
+			"json",
+			"[\n  {\n    \"name\": \"Sandbox\",\n    \"alias\": \"sandbox\"\n  },\n  {\n    \"name\": \"Prod, East\"\n  }\n]\n",
+		},
+		{
+			"yaml",
+			"- name: Sandbox\n  alias: sandbox\n- name: Prod, East\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.output, func(t *testing.T) {
+			var output bytes.Buffer
+			if err := PrintList(&output, test.output, headers, rows, records); err != nil {
+				t.Fatal(err)
+			}
+			if output.String() != test.want {
+				t.Errorf("\ngot:\n%q\nwanted:\n%q", output.String(), test.want)
+			}
+		})
+	}
+
+	if err := PrintList(&bytes.Buffer{}, "xml", headers, rows, records); err == nil {
+		t.Errorf("expected an error for an unsupported output")
+	}
+}
@@ -1,16 +1,9 @@
 package helper
 
 import (
-	"bufio"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
-	"os"
-	"os/user"
-	"path/filepath"
-	"runtime"
-	"strings"
 	"time"
 
 	"github.com/fatih/color"
@@ -23,24 +16,14 @@ import (
 //                                                                            //
 ////////////////////////////////////////////////////////////////////////////////
 
-// PrintSTAK prints out the short term access keys for AWS auth.
-func PrintSTAK(w io.Writer, stak kion.STAK, region string) error {
-	// handle windows vs linux for exports
-	var export string
-	if runtime.GOOS == "windows" {
-		export = "SET"
-	} else {
-		export = "export"
-	}
-
-	// conditionally print region
-	if region != "" {
-		fmt.Fprintf(w, "%v AWS_REGION=%v\n", export, region)
-	}
-
-	// print the stak
-	fmt.Fprintf(w, "%v AWS_ACCESS_KEY_ID=%v\nexport AWS_SECRET_ACCESS_KEY=%v\nexport AWS_SESSION_TOKEN=%v\n", export, stak.AccessKey, stak.SecretAccessKey, stak.SessionToken)
-
+// PrintSTAK prints out the short term access keys for AWS auth as exports
+// for the given shell.
+func PrintSTAK(w io.Writer, stak kion.STAK, region string, shell Shell) error {
+	printExports(w, shell, region,
+		"AWS_ACCESS_KEY_ID", stak.AccessKey,
+		"AWS_SECRET_ACCESS_KEY", stak.SecretAccessKey,
+		"AWS_SESSION_TOKEN", stak.SessionToken,
+	)
 	return nil
 }
 
@@ -54,11 +37,62 @@ func PrintFavoriteConfig(w io.Writer, car kion.CAR, region string, access_type s
 	if region != "" {
 		fmt.Fprintf(w, "    region: %v\n", region)
 	}
-	fmt.Fprintf(w, "    access_type: %v\n\n", access_type)
+	fmt.Fprintf(w, "    access_type: %v\n", access_type)
+
+	// or have the cli do it
+	color.New(color.FgBlue).Fprintf(w, "\nOr save it with:\n")
+	fmt.Fprintf(w, "  kion favorite add --account %v --car %q --access-type %v", car.AccountNumber, car.Name, access_type)
+	if region != "" {
+		fmt.Fprintf(w, " --region %v", region)
+	}
+	color.New(color.FgGreen).Fprintf(w, " [your favorite alias]")
+	fmt.Fprintf(w, "\n\n")
+
+	return nil
+}
+
+// PrintCredentialServerEnv prints out the environment variables that point
+// AWS SDKs at a local container credentials endpoint.
+func PrintCredentialServerEnv(w io.Writer, uri string, token string, region string, shell Shell) error {
+	printExports(w, shell, region, "AWS_CONTAINER_CREDENTIALS_FULL_URI", uri, "AWS_CONTAINER_AUTHORIZATION_TOKEN", token)
+	return nil
+}
 
+// PrintIMDSEnv prints out the environment variables that point AWS SDKs at a
+// local instance metadata service.
+func PrintIMDSEnv(w io.Writer, endpoint string, region string, shell Shell) error {
+	printExports(w, shell, region, "AWS_EC2_METADATA_SERVICE_ENDPOINT", endpoint)
 	return nil
 }
 
+// printExports prints the region, if set, followed by name and value pairs as
+// environment variable exports for the given shell.
+func printExports(w io.Writer, shell Shell, region string, pairs ...string) {
+	// conditionally print region
+	if region != "" {
+		fmt.Fprintln(w, ExportLine(shell, "AWS_REGION", region))
+	}
+
+	for i := 0; i+1 < len(pairs); i += 2 {
+		fmt.Fprintln(w, ExportLine(shell, pairs[i], pairs[i+1]))
+	}
+}
+
+// FormatRemaining renders the time left on credentials compactly for prompts
+// and status lines, ie "1h05m", "42m", "30s", or "expired".
+func FormatRemaining(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return "expired"
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	default:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
+
 // PrintCredentialProcess prints out the short term access keys for use with
 // AWS profiles as a credential process subsystem.
 func PrintCredentialProcess(w io.Writer, stak kion.STAK) error {
@@ -88,126 +122,3 @@ func PrintCredentialProcess(w io.Writer, stak kion.STAK) error {
 
 	return nil
 }
-
-// SaveAWSCreds saves the short term access keys for AWS auth to the users AWS
-// configuration file.
-func SaveAWSCreds(stak kion.STAK, car kion.CAR) error {
-	// get the current user home directory.
-	user, err := user.Current()
-	if err != nil {
-		return err
-	}
-
-	// derive aws creds paths
-	awsCredsDir := filepath.Join(user.HomeDir, ".aws")
-	awsCredsFile := filepath.Join(user.HomeDir, ".aws/credentials")
-
-	// if the folder or file does not exist, create them
-	if _, err := os.Stat(awsCredsDir); os.IsNotExist(err) {
-		// create directory
-		errDir := os.MkdirAll(awsCredsDir, 0755)
-		if errDir != nil {
-			return err
-		}
-	}
-	if _, err := os.Stat(awsCredsFile); os.IsNotExist(err) {
-		err = os.WriteFile(awsCredsFile, []byte(""), 0600)
-		if err != nil {
-			return err
-		}
-	}
-
-	// read in the creds file
-	contents, err := os.ReadFile(awsCredsFile)
-	if err != nil {
-		return err
-	}
-
-	// determine if the profile already exists
-	profileName := fmt.Sprintf("[%v_%v]", car.AccountNumber, car.AwsIamRoleName)
-	index := strings.Index(string(contents), profileName)
-
-	// append the profile if it does not exist, else update it
-	if index == -1 {
-		f, err := os.OpenFile(awsCredsFile, os.O_APPEND|os.O_WRONLY, 0644)
-		if err != nil {
-			return err
-		}
-
-		linebreak := "\n"
-		if runtime.GOOS == "windows" {
-			linebreak = "\r\n"
-		}
-
-		text := ""
-		text += fmt.Sprintf(linebreak+"[%v_%v]"+linebreak, car.AccountNumber, car.AwsIamRoleName)
-		text += fmt.Sprintf("aws_access_key_id=%v"+linebreak, stak.AccessKey)
-		text += fmt.Sprintf("aws_secret_access_key=%v"+linebreak, stak.SecretAccessKey)
-		text += fmt.Sprintf("aws_session_token=%v"+linebreak, stak.SessionToken)
-
-		_, err = f.WriteString(text)
-		if err != nil {
-			return err
-		}
-
-		err = f.Close()
-		if err != nil {
-			return err
-		}
-	} else {
-		f, err := os.Open(awsCredsFile)
-		if err != nil {
-			return err
-		}
-
-		started := false
-
-		buf := ""
-
-		scanner := bufio.NewScanner(f)
-		for scanner.Scan() {
-			t := scanner.Text()
-			if strings.Contains(t, profileName) {
-				started = true
-				buf += fmt.Sprintln(t)
-				continue
-			}
-
-			if started {
-				if !strings.Contains(t, "=") {
-					started = false
-					buf += fmt.Sprintln(t)
-					continue
-				}
-
-				switch true {
-				case strings.Contains(t, "aws_access_key_id"):
-					buf += fmt.Sprintln("aws_access_key_id=" + stak.AccessKey)
-				case strings.Contains(t, "aws_secret_access_key"):
-					buf += fmt.Sprintln("aws_secret_access_key=" + stak.SecretAccessKey)
-				case strings.Contains(t, "aws_session_token"):
-					buf += fmt.Sprintln("aws_session_token=" + stak.SessionToken)
-				default:
-					return errors.New("there is a problem with the aws credentials file")
-				}
-				continue
-			}
-			buf += fmt.Sprintln(t)
-		}
-
-		if err := scanner.Err(); err != nil {
-			return err
-		}
-
-		err = os.WriteFile(awsCredsFile, []byte(buf), 0600)
-		if err != nil {
-			return err
-		}
-	}
-
-	fmt.Println("Credentials updated in the file:", awsCredsFile)
-	fmt.Printf("You can reference this profile using this flag: --profile %v_%v\n", car.AccountNumber, car.AwsIamRoleName)
-	fmt.Printf("Example command: aws s3 ls --profile %v_%v\n", car.AccountNumber, car.AwsIamRoleName)
-
-	return nil
-}
@@ -15,12 +15,14 @@ func TestPrintSTAK(t *testing.T) {
 		description string
 		stak        kion.STAK
 		region      string
+		shell       Shell
 		want        string
 	}{
 		{
 			"Empty",
 			kion.STAK{},
 			"",
+			ShellBash,
 			"export AWS_ACCESS_KEY_ID=\nexport AWS_SECRET_ACCESS_KEY=\nexport AWS_SESSION_TOKEN=\n",
 		},
 		// {
@@ -36,6 +38,7 @@ func TestPrintSTAK(t *testing.T) {
 				SessionToken:    "",
 			},
 			"",
+			ShellBash,
 			"export AWS_ACCESS_KEY_ID=\nexport AWS_SECRET_ACCESS_KEY=aBCDeFg1hijkl2m3NOPqr4StUvWxY56z7abc8DEf\nexport AWS_SESSION_TOKEN=\n",
 		},
 		{
@@ -46,6 +49,7 @@ func TestPrintSTAK(t *testing.T) {
 				SessionToken:    "AbcDEFghIJKlMNoPQrStuVwXYZabcDEfGhI1JklmNoPQRStu2VWXYZaBcd34ef+GH+IJKLmNOPQRSTU5VwxyzABcdeFGHIj6KlMNoPQ7rSTUvW8X9yZAbCD0ef+gHIJkLMnoPqrstUVwxyzAb1CD2e34fgHiJKlMnOPqr56STuvwXyzABcdEfgh7IJK+8LM91No2pqrSTuvWxyz3ABCdEFGH4ijklMNOP5qrs6TUvWxyz789abcDefgH12iJKlM3no4pQRs+5t6UVw7/xy+ZaBcdE+FGhIj8kLmnOpqrstuvw9xyzab1cD/ef23GhIjkLMNoPQrstuv=",
 			},
 			"",
+			ShellBash,
 			"export AWS_ACCESS_KEY_ID=ASIAABCDEFGHIJ1K23LM\nexport AWS_SECRET_ACCESS_KEY=aBCDeFg1hijkl2m3NOPqr4StUvWxY56z7abc8DEf\nexport AWS_SESSION_TOKEN=AbcDEFghIJKlMNoPQrStuVwXYZabcDEfGhI1JklmNoPQRStu2VWXYZaBcd34ef+GH+IJKLmNOPQRSTU5VwxyzABcdeFGHIj6KlMNoPQ7rSTUvW8X9yZAbCD0ef+gHIJkLMnoPqrstUVwxyzAb1CD2e34fgHiJKlMnOPqr56STuvwXyzABcdEfgh7IJK+8LM91No2pqrSTuvWxyz3ABCdEFGH4ijklMNOP5qrs6TUvWxyz789abcDefgH12iJKlM3no4pQRs+5t6UVw7/xy+ZaBcdE+FGhIj8kLmnOpqrstuvw9xyzab1cD/ef23GhIjkLMNoPQrstuv=\n",
 		},
 		{
@@ -56,9 +60,53 @@ func TestPrintSTAK(t *testing.T) {
 				SessionToken:    "AbcDEFghIJKlMNoPQrStuVwXYZabcDEfGhI1JklmNoPQRStu2VWXYZaBcd34ef+GH+IJKLmNOPQRSTU5VwxyzABcdeFGHIj6KlMNoPQ7rSTUvW8X9yZAbCD0ef+gHIJkLMnoPqrstUVwxyzAb1CD2e34fgHiJKlMnOPqr56STuvwXyzABcdEfgh7IJK+8LM91No2pqrSTuvWxyz3ABCdEFGH4ijklMNOP5qrs6TUvWxyz789abcDefgH12iJKlM3no4pQRs+5t6UVw7/xy+ZaBcdE+FGhIj8kLmnOpqrstuvw9xyzab1cD/ef23GhIjkLMNoPQrstuv=",
 			},
 			"us-gov-west-1",
+			ShellBash,
 			"export AWS_REGION=us-gov-west-1\nexport AWS_ACCESS_KEY_ID=ASIAABCDEFGHIJ1K23LM\nexport AWS_SECRET_ACCESS_KEY=aBCDeFg1hijkl2m3NOPqr4StUvWxY56z7abc8DEf\nexport AWS_SESSION_TOKEN=AbcDEFghIJKlMNoPQrStuVwXYZabcDEfGhI1JklmNoPQRStu2VWXYZaBcd34ef+GH+IJKLmNOPQRSTU5VwxyzABcdeFGHIj6KlMNoPQ7rSTUvW8X9yZAbCD0ef+gHIJkLMnoPqrstUVwxyzAb1CD2e34fgHiJKlMnOPqr56STuvwXyzABcdEfgh7IJK+8LM91No2pqrSTuvWxyz3ABCdEFGH4ijklMNOP5qrs6TUvWxyz789abcDefgH12iJKlM3no4pQRs+5t6UVw7/xy+ZaBcdE+FGhIj8kLmnOpqrstuvw9xyzab1cD/ef23GhIjkLMNoPQrstuv=\n",
 		},
-		// TODO: add test that would print SETs for windows
+		{
+			"Fish",
+			kion.STAK{
+				AccessKey:       "ASIAABCDEFGHIJ1K23LM",
+				SecretAccessKey: "aBCDeFg1hijkl2m3NOPqr4StUvWxY56z7abc8DEf",
+				SessionToken:    "AbcDEF+gh/IJ=",
+			},
+			"us-east-1",
+			ShellFish,
+			"set -gx AWS_REGION 'us-east-1';\nset -gx AWS_ACCESS_KEY_ID 'ASIAABCDEFGHIJ1K23LM';\nset -gx AWS_SECRET_ACCESS_KEY 'aBCDeFg1hijkl2m3NOPqr4StUvWxY56z7abc8DEf';\nset -gx AWS_SESSION_TOKEN 'AbcDEF+gh/IJ=';\n",
+		},
+		{
+			"PowerShell",
+			kion.STAK{
+				AccessKey:       "ASIAABCDEFGHIJ1K23LM",
+				SecretAccessKey: "aBCDeFg1hijkl2m3NOPqr4StUvWxY56z7abc8DEf",
+				SessionToken:    "AbcDEF+gh/IJ=",
+			},
+			"us-east-1",
+			ShellPowerShell,
+			"$env:AWS_REGION = 'us-east-1'\n$env:AWS_ACCESS_KEY_ID = 'ASIAABCDEFGHIJ1K23LM'\n$env:AWS_SECRET_ACCESS_KEY = 'aBCDeFg1hijkl2m3NOPqr4StUvWxY56z7abc8DEf'\n$env:AWS_SESSION_TOKEN = 'AbcDEF+gh/IJ='\n",
+		},
+		{
+			"Nushell",
+			kion.STAK{
+				AccessKey:       "ASIAABCDEFGHIJ1K23LM",
+				SecretAccessKey: "aBCDeFg1hijkl2m3NOPqr4StUvWxY56z7abc8DEf",
+				SessionToken:    "AbcDEF+gh/IJ=",
+			},
+			"us-east-1",
+			ShellNushell,
+			"$env.AWS_REGION = \"us-east-1\"\n$env.AWS_ACCESS_KEY_ID = \"ASIAABCDEFGHIJ1K23LM\"\n$env.AWS_SECRET_ACCESS_KEY = \"aBCDeFg1hijkl2m3NOPqr4StUvWxY56z7abc8DEf\"\n$env.AWS_SESSION_TOKEN = \"AbcDEF+gh/IJ=\"\n",
+		},
+		{
+			"Windows Cmd",
+			kion.STAK{
+				AccessKey:       "ASIAABCDEFGHIJ1K23LM",
+				SecretAccessKey: "aBCDeFg1hijkl2m3NOPqr4StUvWxY56z7abc8DEf",
+				SessionToken:    "AbcDEF+gh/IJ=",
+			},
+			"us-east-1",
+			ShellCmd,
+			"SET AWS_REGION=us-east-1\nSET AWS_ACCESS_KEY_ID=ASIAABCDEFGHIJ1K23LM\nSET AWS_SECRET_ACCESS_KEY=aBCDeFg1hijkl2m3NOPqr4StUvWxY56z7abc8DEf\nSET AWS_SESSION_TOKEN=AbcDEF+gh/IJ=\n",
+		},
 	}
 
 	for _, test := range tests {
@@ -73,7 +121,7 @@ func TestPrintSTAK(t *testing.T) {
 			}()
 
 			var output bytes.Buffer
-			err := PrintSTAK(&output, test.stak, test.region)
+			err := PrintSTAK(&output, test.stak, test.region, test.shell)
 			if err != nil {
 				t.Error(err)
 			}
@@ -152,3 +200,23 @@ func TestPrintCredentialProcess(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatRemaining(t *testing.T) {
+	tests := []struct {
+		remaining time.Duration
+		want      string
+	}{
+		{-time.Minute, "expired"},
+		{0, "expired"},
+		{30 * time.Second, "30s"},
+		{42*time.Minute + 10*time.Second, "42m"},
+		{time.Hour + 5*time.Minute, "1h05m"},
+		{11 * time.Hour, "11h00m"},
+	}
+
+	for _, test := range tests {
+		if got := FormatRemaining(test.remaining); got != test.want {
+			t.Errorf("FormatRemaining(%v): got %q, wanted %q", test.remaining, got, test.want)
+		}
+	}
+}
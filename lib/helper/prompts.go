@@ -2,6 +2,7 @@ package helper
 
 import (
 	"os"
+	"slices"
 
 	"github.com/charmbracelet/huh"
 	"github.com/kionsoftware/kion-cli/lib/styles"
@@ -116,3 +117,36 @@ func PromptPassword(message string) (string, error) {
 
 	return input, nil
 }
+
+// PromptMultiSelect prompts the user to select any number of options, with
+// the given options selected to start with.
+func PromptMultiSelect(message string, description string, options []string, selected []string) ([]string, error) {
+	var selection []string
+
+	// Convert to huh options
+	huhOptions := make([]huh.Option[string], len(options))
+	for i, option := range options {
+		huhOptions[i] = huh.NewOption(option, option).Selected(slices.Contains(selected, option))
+	}
+
+	selectField := huh.NewMultiSelect[string]().
+		Title(message).
+		Description(description).
+		Options(huhOptions...).
+		Value(&selection)
+
+	// Apply height limiting only if needed
+	if shouldLimit, height := shouldLimitHeight(len(options)); shouldLimit {
+		selectField = selectField.Height(height)
+	}
+
+	form := huh.NewForm(
+		huh.NewGroup(selectField),
+	).WithTheme(styles.FormTheme)
+
+	if err := form.Run(); err != nil {
+		return nil, err
+	}
+
+	return selection, nil
+}
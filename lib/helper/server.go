@@ -0,0 +1,343 @@
+package helper
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kionsoftware/kion-cli/lib/kion"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+//                                                                            //
+//  Credential Server                                                         //
+//                                                                            //
+////////////////////////////////////////////////////////////////////////////////
+
+// STAKRefreshWindow is how long before a STAK expires that a replacement is
+// fetched. AWS SDKs start asking for new credentials 15 minutes out, so
+// anything shorter would hand them keys they immediately consider stale.
+const STAKRefreshWindow = 15 * time.Minute
+
+// STAKSource returns a STAK that is valid beyond the refresh window, either
+// from the cache or freshly minted by Kion.
+type STAKSource func(ctx context.Context) (kion.STAK, error)
+
+// RefreshingSTAK holds a STAK and replaces it from its source as it nears
+// expiration. It is safe for concurrent use.
+type RefreshingSTAK struct {
+	source STAKSource
+	mu     sync.Mutex
+	stak   kion.STAK
+}
+
+// NewRefreshingSTAK returns a RefreshingSTAK backed by the given source.
+func NewRefreshingSTAK(source STAKSource) *RefreshingSTAK {
+	return &RefreshingSTAK{source: source}
+}
+
+// Get returns the current STAK, fetching a new one from the source first if
+// it is missing or inside the refresh window.
+func (r *RefreshingSTAK) Get(ctx context.Context) (kion.STAK, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if STAKIsFresh(r.stak, time.Now()) {
+		return r.stak, nil
+	}
+
+	stak, err := r.source(ctx)
+	if err != nil {
+		return kion.STAK{}, err
+	}
+	r.stak = stak
+
+	return stak, nil
+}
+
+// Set replaces the current STAK, used to seed it with keys already in hand.
+func (r *RefreshingSTAK) Set(stak kion.STAK) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stak = stak
+}
+
+// STAKIsFresh reports whether a STAK is still outside of its refresh window.
+// The window is capped at half the STAK duration so that short lived keys
+// are not refreshed on every request.
+func STAKIsFresh(stak kion.STAK, now time.Time) bool {
+	if stak == (kion.STAK{}) {
+		return false
+	}
+	window := STAKRefreshWindow
+	if lifetime := time.Duration(stak.Duration) * time.Second; lifetime > 0 {
+		window = min(window, lifetime/2)
+	}
+	return now.Before(stak.Expiration.Add(-window))
+}
+
+// NewAuthToken returns a random token used to authorize requests made to a
+// local credential server.
+func NewAuthToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ecsCredentials is the response body AWS SDKs expect from a container
+// credentials endpoint.
+type ecsCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+	Expiration      string `json:"Expiration"`
+}
+
+// credentialError is the error body returned by a credential server.
+type credentialError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewECSCredentialsHandler returns a handler compatible with the ECS container
+// credentials endpoint, as used by AWS SDKs when the
+// AWS_CONTAINER_CREDENTIALS_FULL_URI and AWS_CONTAINER_AUTHORIZATION_TOKEN
+// environment variables are set. Requests without the token are rejected.
+func NewECSCredentialsHandler(creds *RefreshingSTAK, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, credentialError{"MethodNotAllowed", "only GET is supported"})
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, credentialError{"AccessDenied", "invalid authorization token"})
+			return
+		}
+
+		stak, err := creds.Get(r.Context())
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, credentialError{"CredentialsUnavailable", err.Error()})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, ecsCredentials{
+			AccessKeyID:     stak.AccessKey,
+			SecretAccessKey: stak.SecretAccessKey,
+			Token:           stak.SessionToken,
+			Expiration:      stak.Expiration.UTC().Format(time.RFC3339),
+		})
+	})
+}
+
+// imdsCredentials is the response body AWS SDKs expect from the instance
+// metadata service security credentials path.
+type imdsCredentials struct {
+	Code            string `json:"Code"`
+	LastUpdated     string `json:"LastUpdated"`
+	Type            string `json:"Type"`
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+	Expiration      string `json:"Expiration"`
+}
+
+// imdsMaxTokenTTL is the longest session token lifetime IMDSv2 allows.
+const imdsMaxTokenTTL = 6 * time.Hour
+
+// imdsHandler emulates the parts of the EC2 instance metadata service used to
+// discover credentials.
+type imdsHandler struct {
+	creds  *RefreshingSTAK
+	role   string
+	region string
+	mu     sync.Mutex
+	tokens map[string]time.Time
+}
+
+// NewIMDSHandler returns a handler emulating the IMDSv2 session token
+// handshake and the security credentials paths of the EC2 instance metadata
+// service. Credentials are served under the given role name and the region,
+// if set, under the placement path. IMDSv1 requests without a session token
+// are rejected.
+func NewIMDSHandler(creds *RefreshingSTAK, role string, region string) http.Handler {
+	return &imdsHandler{
+		creds:  creds,
+		role:   role,
+		region: region,
+		tokens: make(map[string]time.Time),
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *imdsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/latest/api/token" {
+		h.issueToken(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.validToken(r.Header.Get("X-aws-ec2-metadata-token")) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	credsPath := "/latest/meta-data/iam/security-credentials/"
+	switch {
+	case r.URL.Path == credsPath || r.URL.Path == strings.TrimSuffix(credsPath, "/"):
+		writeText(w, h.role)
+	case strings.HasPrefix(r.URL.Path, credsPath):
+		if strings.TrimPrefix(r.URL.Path, credsPath) != h.role {
+			http.NotFound(w, r)
+			return
+		}
+		stak, err := h.creds.Get(r.Context())
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, credentialError{"CredentialsUnavailable", err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, imdsCredentials{
+			Code:            "Success",
+			LastUpdated:     time.Now().UTC().Format(time.RFC3339),
+			Type:            "AWS-HMAC",
+			AccessKeyID:     stak.AccessKey,
+			SecretAccessKey: stak.SecretAccessKey,
+			Token:           stak.SessionToken,
+			Expiration:      stak.Expiration.UTC().Format(time.RFC3339),
+		})
+	case r.URL.Path == "/latest/meta-data/placement/region" && h.region != "":
+		writeText(w, h.region)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// issueToken handles the IMDSv2 session token handshake.
+func (h *imdsHandler) issueToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// like imds, refuse requests that were forwarded by a proxy
+	if r.Header.Get("X-Forwarded-For") != "" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	seconds, err := strconv.Atoi(r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds"))
+	ttl := time.Duration(seconds) * time.Second
+	if err != nil || ttl < time.Second || ttl > imdsMaxTokenTTL {
+		http.Error(w, "invalid token ttl", http.StatusBadRequest)
+		return
+	}
+
+	token, err := NewAuthToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.mu.Lock()
+	now := time.Now()
+	for t, expiry := range h.tokens {
+		if now.After(expiry) {
+			delete(h.tokens, t)
+		}
+	}
+	h.tokens[token] = now.Add(ttl)
+	h.mu.Unlock()
+
+	w.Header().Set("X-aws-ec2-metadata-token-ttl-seconds", strconv.Itoa(seconds))
+	writeText(w, token)
+}
+
+// validToken reports whether a session token was issued and has not expired.
+func (h *imdsHandler) validToken(token string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	expiry, found := h.tokens[token]
+	return found && time.Now().Before(expiry)
+}
+
+// ServeCredentials serves the handler on the listener until the context is
+// done, then shuts the server down gracefully.
+func ServeCredentials(ctx context.Context, ln net.Listener, handler http.Handler) error {
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Serve(ln)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err := server.Shutdown(shutdownCtx)
+		if serveErr := <-errs; !errors.Is(serveErr, http.ErrServerClosed) {
+			return serveErr
+		}
+		return err
+	}
+}
+
+// CredentialServerExpiration asks a container credentials endpoint, such as
+// the one owned by a Kion CLI sub-shell, when the keys it currently serves
+// expire.
+func CredentialServerExpiration(ctx context.Context, uri string, token string) (time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Authorization", token)
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("credential endpoint returned %v", resp.Status)
+	}
+
+	var creds ecsCredentials
+	if err := json.NewDecoder(resp.Body).Decode(&creds); err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, creds.Expiration)
+}
+
+// writeText writes a plain text response.
+func writeText(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write([]byte(body))
+}
+
+// writeJSON writes a JSON response with the given status.
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,169 @@
+package helper
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kionsoftware/kion-cli/lib/kion"
+)
+
+func TestSTAKIsFresh(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		description string
+		stak        kion.STAK
+		want        bool
+	}{
+		{
+			"Empty",
+			kion.STAK{},
+			false,
+		},
+		{
+			"Hour Left",
+			kion.STAK{AccessKey: "a", Duration: 3600, Expiration: now.Add(time.Hour)},
+			true,
+		},
+		{
+			"Inside Window",
+			kion.STAK{AccessKey: "a", Duration: 3600, Expiration: now.Add(10 * time.Minute)},
+			false,
+		},
+		{
+			"Short Lived",
+			kion.STAK{AccessKey: "a", Duration: 900, Expiration: now.Add(10 * time.Minute)},
+			true,
+		},
+		{
+			"Expired",
+			kion.STAK{AccessKey: "a", Duration: 900, Expiration: now.Add(-time.Minute)},
+			false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			if got := STAKIsFresh(test.stak, now); got != test.want {
+				t.Errorf("got %v, wanted %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestECSCredentialsHandler(t *testing.T) {
+	// hand out a stak that is always inside the refresh window
+	calls := 0
+	creds := NewRefreshingSTAK(func(context.Context) (kion.STAK, error) {
+		calls++
+		return kion.STAK{
+			AccessKey:       "AKIA",
+			SecretAccessKey: "secret",
+			SessionToken:    "session",
+			Duration:        3600,
+			Expiration:      time.Now().Add(5 * time.Minute),
+		}, nil
+	})
+	handler := NewECSCredentialsHandler(creds, "auth-token")
+
+	// missing token
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/credentials", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("without token: got status %d, wanted %d", rec.Code, http.StatusUnauthorized)
+	}
+	if calls != 0 {
+		t.Errorf("unauthorized request fetched credentials")
+	}
+
+	// valid token, twice, each should refresh
+	for i := 1; i <= 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/credentials", nil)
+		req.Header.Set("Authorization", "auth-token")
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got status %d: %s", rec.Code, rec.Body.String())
+		}
+
+		var body map[string]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid json: %v", err)
+		}
+		if body["AccessKeyId"] != "AKIA" || body["SecretAccessKey"] != "secret" || body["Token"] != "session" {
+			t.Errorf("unexpected credentials: %v", body)
+		}
+		if _, err := time.Parse(time.RFC3339, body["Expiration"]); err != nil {
+			t.Errorf("expiration not RFC3339: %q", body["Expiration"])
+		}
+		if calls != i {
+			t.Errorf("got %d fetches, wanted %d", calls, i)
+		}
+	}
+}
+
+func TestIMDSHandler(t *testing.T) {
+	creds := NewRefreshingSTAK(func(context.Context) (kion.STAK, error) {
+		return kion.STAK{
+			AccessKey:       "AKIA",
+			SecretAccessKey: "secret",
+			SessionToken:    "session",
+			Duration:        3600,
+			Expiration:      time.Now().Add(time.Hour),
+		}, nil
+	})
+	handler := NewIMDSHandler(creds, "sandbox-admin", "us-east-1")
+
+	request := func(method string, path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// imdsv1 requests are refused
+	if rec := request(http.MethodGet, "/latest/meta-data/iam/security-credentials/", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without token: got status %d, wanted %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	// token handshake
+	if rec := request(http.MethodPut, "/latest/api/token", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("without ttl: got status %d, wanted %d", rec.Code, http.StatusBadRequest)
+	}
+	rec := request(http.MethodPut, "/latest/api/token", map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "21600"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("token: got status %d", rec.Code)
+	}
+	auth := map[string]string{"X-aws-ec2-metadata-token": rec.Body.String()}
+
+	// role discovery
+	rec = request(http.MethodGet, "/latest/meta-data/iam/security-credentials/", auth)
+	if rec.Body.String() != "sandbox-admin" {
+		t.Errorf("role: got %q", rec.Body.String())
+	}
+	if rec = request(http.MethodGet, "/latest/meta-data/iam/security-credentials/other", auth); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown role: got status %d, wanted %d", rec.Code, http.StatusNotFound)
+	}
+
+	// credentials
+	rec = request(http.MethodGet, "/latest/meta-data/iam/security-credentials/sandbox-admin", auth)
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if body["Code"] != "Success" || body["AccessKeyId"] != "AKIA" || body["Token"] != "session" {
+		t.Errorf("unexpected credentials: %v", body)
+	}
+
+	// region
+	rec = request(http.MethodGet, "/latest/meta-data/placement/region", auth)
+	if rec.Body.String() != "us-east-1" {
+		t.Errorf("region: got %q", rec.Body.String())
+	}
+}
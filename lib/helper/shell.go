@@ -6,8 +6,10 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/kionsoftware/kion-cli/lib/kion"
@@ -19,10 +21,257 @@ import (
 //                                                                            //
 ////////////////////////////////////////////////////////////////////////////////
 
-// CreateSubShell creates a sub-shell containing set variables for AWS short
-// term access keys. It attempts to use the users configured shell and rc file
-// while overriding the prompt to indicate the authed AWS account.
-func CreateSubShell(accountNumber string, accountAlias string, carName string, stak kion.STAK, region string) error {
+// awsCredentialVars are inherited environment variables that AWS tools would
+// prefer over the credentials handed to a sub-shell.
+var awsCredentialVars = []string{
+	"AWS_ACCESS_KEY_ID",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+	"AWS_PROFILE",
+	"AWS_DEFAULT_PROFILE",
+	"AWS_CONTAINER_CREDENTIALS_FULL_URI",
+	"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI",
+	"AWS_CONTAINER_AUTHORIZATION_TOKEN",
+	"AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE",
+}
+
+// STAKEnv returns the environment variables that hand static short term
+// access keys to AWS tools.
+func STAKEnv(stak kion.STAK) []string {
+	return []string{
+		fmt.Sprintf("AWS_ACCESS_KEY_ID=%s", stak.AccessKey),
+		fmt.Sprintf("AWS_SECRET_ACCESS_KEY=%s", stak.SecretAccessKey),
+		fmt.Sprintf("AWS_SESSION_TOKEN=%s", stak.SessionToken),
+	}
+}
+
+// CredentialServerEnv returns the environment variables that point AWS tools
+// at a local container credentials endpoint.
+func CredentialServerEnv(uri string, token string) []string {
+	return []string{
+		fmt.Sprintf("AWS_CONTAINER_CREDENTIALS_FULL_URI=%s", uri),
+		fmt.Sprintf("AWS_CONTAINER_AUTHORIZATION_TOKEN=%s", token),
+	}
+}
+
+// ExpirationEnv returns the environment variable that records when the keys
+// handed to a sub-shell expire, read by `kion status` and prompt tools.
+func ExpirationEnv(expiration time.Time) string {
+	return fmt.Sprintf("KION_STAK_EXPIRATION=%s", expiration.UTC().Format(time.RFC3339))
+}
+
+// Shell is a shell dialect that sub-shells are started in and environment
+// variable exports are rendered for.
+type Shell string
+
+const (
+	ShellBash       Shell = "bash"
+	ShellZsh        Shell = "zsh"
+	ShellFish       Shell = "fish"
+	ShellPowerShell Shell = "powershell"
+	ShellNushell    Shell = "nu"
+	ShellCmd        Shell = "cmd"
+)
+
+// shellNames maps shell names and binaries to the dialect they speak.
+var shellNames = map[string]Shell{
+	"bash":       ShellBash,
+	"sh":         ShellBash,
+	"ksh":        ShellBash,
+	"zsh":        ShellZsh,
+	"fish":       ShellFish,
+	"powershell": ShellPowerShell,
+	"pwsh":       ShellPowerShell,
+	"nu":         ShellNushell,
+	"nushell":    ShellNushell,
+	"cmd":        ShellCmd,
+}
+
+// ParseShell returns the shell dialect for a shell name or binary, ie "fish",
+// "pwsh", or "nu.exe".
+func ParseShell(name string) (Shell, error) {
+	shell, found := shellNames[strings.TrimSuffix(strings.ToLower(name), ".exe")]
+	if !found {
+		return "", fmt.Errorf("unsupported shell: %v, expected bash, zsh, fish, powershell, nu, or cmd", name)
+	}
+	return shell, nil
+}
+
+// DetectShell returns the shell dialect to use, the override if one was given
+// or else the users shell. Unrecognized shells fall back to bash.
+func DetectShell(override string) (Shell, error) {
+	if override != "" {
+		return ParseShell(override)
+	}
+
+	if usrShell := os.Getenv("SHELL"); usrShell != "" {
+		if shell, err := ParseShell(filepath.Base(usrShell)); err == nil {
+			return shell, nil
+		}
+		return ShellBash, nil
+	}
+
+	if runtime.GOOS == "windows" {
+		// powershell adds the users own module directory to PSModulePath while
+		// cmd only carries the system wide entries
+		home, err := os.UserHomeDir()
+		if err == nil && strings.Contains(strings.ToLower(os.Getenv("PSModulePath")), strings.ToLower(home)) {
+			return ShellPowerShell, nil
+		}
+		return ShellCmd, nil
+	}
+
+	return ShellBash, nil
+}
+
+// ExportLine renders a statement that sets an environment variable in the
+// given shell.
+func ExportLine(shell Shell, name string, value string) string {
+	switch shell {
+	case ShellFish:
+		// fish joins the lines of a command substitution before eval runs them,
+		// so each statement is terminated
+		return fmt.Sprintf("set -gx %s %s;", name, quoteFish(value))
+	case ShellPowerShell:
+		return fmt.Sprintf("$env:%s = %s", name, quotePowerShell(value))
+	case ShellNushell:
+		return fmt.Sprintf("$env.%s = %s", name, quoteNushell(value))
+	case ShellCmd:
+		return fmt.Sprintf("SET %s=%s", name, value)
+	default:
+		return fmt.Sprintf("export %s=%s", name, quotePOSIX(value))
+	}
+}
+
+// quotePOSIX single quotes a value for sh compatible shells unless it is made
+// up entirely of characters that are safe bare, as keys and regions are.
+func quotePOSIX(value string) string {
+	safe := strings.IndexFunc(value, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_-+=/:.,@%", r))
+	}) == -1
+	if safe {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// quoteFish single quotes a value for fish.
+func quoteFish(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(value) + "'"
+}
+
+// quotePowerShell single quotes a value for PowerShell.
+func quotePowerShell(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// quoteNushell double quotes a value for nushell.
+func quoteNushell(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// shellBinary returns the binary to start a sub-shell of the given dialect
+// with, preferring the users own shell when it matches.
+func shellBinary(shell Shell) string {
+	if usrShell := os.Getenv("SHELL"); usrShell != "" {
+		if parsed, err := ParseShell(filepath.Base(usrShell)); err == nil && parsed == shell {
+			return usrShell
+		}
+	}
+	if shell == ShellPowerShell {
+		if _, err := exec.LookPath("pwsh"); err == nil {
+			return "pwsh"
+		}
+	}
+	return string(shell)
+}
+
+// promptCountdown returns a prompt snippet that runs `kion status` to show how
+// long the sub-shell credentials remain valid, empty if the running binary
+// can't be safely referenced. The snippet is escaped for the double quoted
+// strings the bash and zsh prompts are written in.
+func promptCountdown() string {
+	exe, err := os.Executable()
+	if err != nil || strings.ContainsAny(exe, "\"'$`\\") {
+		return ""
+	}
+	return fmt.Sprintf(` \$(\"%s\" status --output prompt)`, exe)
+}
+
+// fishPrompt returns init commands that prefix the users fish prompt with the
+// account and the time left on its credentials.
+func fishPrompt(accountMeta string, exe string) string {
+	var b strings.Builder
+	b.WriteString("functions -c fish_prompt __kion_fish_prompt\n")
+	b.WriteString("function __kion_restore_status\n    return $argv[1]\nend\n")
+	b.WriteString("function fish_prompt\n    set -l kion_status $status\n")
+	if exe != "" {
+		fmt.Fprintf(&b, "    set -l kion_left (%s status --output prompt)\n", quoteFish(exe))
+	}
+	fmt.Fprintf(&b, "    set_color green\n    echo -n %s\n", quoteFish("["+accountMeta))
+	if exe != "" {
+		b.WriteString("    test -n \"$kion_left\"; and echo -n \" $kion_left\"\n")
+	}
+	b.WriteString("    echo -n '] '\n    set_color normal\n")
+	b.WriteString("    __kion_restore_status $kion_status\n    __kion_fish_prompt\nend\n")
+	return b.String()
+}
+
+// powerShellPrompt returns a script that prefixes the users PowerShell prompt
+// with the account and the time left on its credentials.
+func powerShellPrompt(accountMeta string, exe string) string {
+	left := "$null"
+	if exe != "" {
+		left = fmt.Sprintf("& %s status --output prompt", quotePowerShell(exe))
+	}
+	return fmt.Sprintf(`$global:KionPrompt = $function:prompt
+function global:prompt {
+    $kionExit = $global:LASTEXITCODE
+    $kionLeft = %s
+    $kionMeta = %s
+    if ($kionLeft) { $kionMeta += " $kionLeft" }
+    Write-Host "[$kionMeta]" -NoNewline -ForegroundColor Green
+    $global:LASTEXITCODE = $kionExit
+    ' ' + (& $global:KionPrompt)
+}
+`, left, quotePowerShell(accountMeta))
+}
+
+// nushellPrompt returns commands that prefix the users nushell prompt with the
+// account and the time left on its credentials.
+func nushellPrompt(accountMeta string, exe string) string {
+	left := `""`
+	if exe != "" {
+		left = fmt.Sprintf("(^%s status --output prompt | str trim)", quoteNushell(exe))
+	}
+	return fmt.Sprintf(`let kion_prompt = ($env.PROMPT_COMMAND? | default "")
+$env.PROMPT_COMMAND = {||
+    let left = %s
+    let original = (if ($kion_prompt | describe) == "closure" { do $kion_prompt } else { $kion_prompt })
+    (ansi green) + "[" + %s + (if ($left | is-empty) { "" } else { " " + $left }) + "] " + (ansi reset) + $original
+}
+`, left, quoteNushell(accountMeta))
+}
+
+// withoutAWSCredentials returns the environment minus any inherited AWS
+// credential variables.
+func withoutAWSCredentials(env []string) []string {
+	clean := make([]string, 0, len(env))
+	for _, entry := range env {
+		name, _, _ := strings.Cut(entry, "=")
+		if !slices.Contains(awsCredentialVars, name) {
+			clean = append(clean, entry)
+		}
+	}
+	return clean
+}
+
+// CreateSubShell creates a sub-shell containing set variables for AWS
+// credentials, either static short term access keys from STAKEnv or a local
+// endpoint from CredentialServerEnv. It starts the given shell with the users
+// own configuration while prefixing the prompt to indicate the authed AWS
+// account.
+func CreateSubShell(shellType Shell, accountNumber string, accountAlias string, carName string, credentials []string, region string) error {
 	// check if we know the account name
 	var accountMeta string
 	var accountMetaSentence string
@@ -30,7 +279,7 @@ func CreateSubShell(accountNumber string, accountAlias string, carName string, s
 		accountMeta = accountNumber
 		accountMetaSentence = accountNumber
 	} else {
-		if runtime.GOOS == "windows" {
+		if shellType == ShellCmd {
 			accountMeta = fmt.Sprintf("%v^|%v", accountAlias, accountNumber)
 		} else {
 			accountMeta = fmt.Sprintf("%v|%v", accountAlias, accountNumber)
@@ -38,15 +287,16 @@ func CreateSubShell(accountNumber string, accountAlias string, carName string, s
 		accountMetaSentence = fmt.Sprintf("%v (%v)", accountAlias, accountNumber)
 	}
 
-	// get users shell information
-	usrShellPath := os.Getenv("SHELL")
-	usrShellName := filepath.Base(usrShellPath)
-	usrHistFile := os.Getenv("HISTFILE")
+	// the running binary reports the time left on the credentials
+	exe, err := os.Executable()
+	if err != nil {
+		exe = ""
+	}
 
-	// create command based on the users shell and set prompt
-	var cmd string
-	switch usrShellName {
-	case "zsh":
+	// create the command for the shell and set its prompt
+	var shell *exec.Cmd
+	switch shellType {
+	case ShellZsh:
 		zdotdir, err := os.MkdirTemp("", "kionzrootdir")
 		if err != nil {
 			return err
@@ -56,32 +306,28 @@ func CreateSubShell(accountNumber string, accountAlias string, carName string, s
 		if err != nil {
 			return err
 		}
-		fmt.Fprintf(f, `HISTFILE=%s; source $HOME/.zshrc; autoload -U colors && colors; export PS1="%%F{green}[%v]%%b%%f $PS1"`, usrHistFile, accountMeta)
+		fmt.Fprintf(f, `HISTFILE=%s; source $HOME/.zshrc; autoload -U colors && colors; setopt PROMPT_SUBST; export PS1="%%F{green}[%v%v]%%b%%f $PS1"`, os.Getenv("HISTFILE"), accountMeta, promptCountdown())
 		err = f.Sync()
 		if err != nil {
 			return err
 		}
-		cmd = fmt.Sprintf(`ZDOTDIR=%v zsh`, zdotdir)
-	case "bash":
-		cmd = fmt.Sprintf(`bash --rcfile <(echo "source \"$HOME/.bashrc\"; export PS1='[%v] > '")`, accountMeta)
-	default:
-		cmd = fmt.Sprintf(`bash --rcfile <(echo "source \"$HOME/.bashrc\"; export PS1='[%v] > '")`, accountMeta)
-	}
-
-	// init shell
-	var shell *exec.Cmd
-	if runtime.GOOS == "windows" && usrShellName == "" {
+		shell = exec.Command("bash", "-c", fmt.Sprintf(`ZDOTDIR=%v %v`, zdotdir, shellBinary(ShellZsh)))
+	case ShellFish:
+		shell = exec.Command(shellBinary(ShellFish), "-C", fishPrompt(accountMeta, exe))
+	case ShellPowerShell:
+		shell = exec.Command(shellBinary(ShellPowerShell), "-NoExit", "-Command", powerShellPrompt(accountMeta, exe))
+	case ShellNushell:
+		shell = exec.Command(shellBinary(ShellNushell), "-e", nushellPrompt(accountMeta, exe))
+	case ShellCmd:
 		cmdPath := "C:\\Windows\\System32\\cmd.exe"
 		shell = exec.Command(cmdPath, "/K", fmt.Sprintf(`PROMPT $E[32m[%s]$E[0m$G`, accountMeta))
-	} else {
-		shell = exec.Command("bash", "-c", cmd)
+	default:
+		shell = exec.Command("bash", "-c", fmt.Sprintf(`bash --rcfile <(echo "source \"$HOME/.bashrc\"; export PS1='[%v%v] > '")`, accountMeta, promptCountdown()))
 	}
 
-	// replicate current env vars and add stak
-	shell.Env = os.Environ()
-	shell.Env = append(shell.Env, fmt.Sprintf("AWS_ACCESS_KEY_ID=%s", stak.AccessKey))
-	shell.Env = append(shell.Env, fmt.Sprintf("AWS_SECRET_ACCESS_KEY=%s", stak.SecretAccessKey))
-	shell.Env = append(shell.Env, fmt.Sprintf("AWS_SESSION_TOKEN=%s", stak.SessionToken))
+	// replicate current env vars and add credentials
+	shell.Env = withoutAWSCredentials(os.Environ())
+	shell.Env = append(shell.Env, credentials...)
 	shell.Env = append(shell.Env, fmt.Sprintf("KION_ACCOUNT_NUM=%s", accountNumber))
 	shell.Env = append(shell.Env, fmt.Sprintf("KION_ACCOUNT_ALIAS=%s", accountAlias))
 	shell.Env = append(shell.Env, fmt.Sprintf("KION_CAR=%s", carName))
@@ -98,7 +344,7 @@ func CreateSubShell(accountNumber string, accountAlias string, carName string, s
 
 	// run the shell
 	color.Green("Starting session for %v", accountMetaSentence)
-	err := shell.Run()
+	err = shell.Run()
 	color.Green("Shutting down session for %v", accountMetaSentence)
 
 	return err
@@ -0,0 +1,58 @@
+package helper
+
+import (
+	"testing"
+)
+
+func TestExportLineQuoting(t *testing.T) {
+	tests := []struct {
+		shell Shell
+		want  string
+	}{
+		{ShellBash, `export NAME='it'\''s $HOME'`},
+		{ShellZsh, `export NAME='it'\''s $HOME'`},
+		{ShellFish, `set -gx NAME 'it\'s $HOME';`},
+		{ShellPowerShell, `$env:NAME = 'it''s $HOME'`},
+		{ShellNushell, `$env.NAME = "it's $HOME"`},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.shell), func(t *testing.T) {
+			if got := ExportLine(test.shell, "NAME", "it's $HOME"); got != test.want {
+				t.Errorf("\ngot:\n  %v\nwanted:\n  %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseShell(t *testing.T) {
+	tests := []struct {
+		name string
+		want Shell
+	}{
+		{"bash", ShellBash},
+		{"zsh", ShellZsh},
+		{"fish", ShellFish},
+		{"pwsh", ShellPowerShell},
+		{"PowerShell.exe", ShellPowerShell},
+		{"nu", ShellNushell},
+		{"nushell", ShellNushell},
+		{"cmd.exe", ShellCmd},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseShell(test.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %v, wanted %v", got, test.want)
+			}
+		})
+	}
+
+	if _, err := ParseShell("tcsh"); err == nil {
+		t.Error("expected an error for an unsupported shell")
+	}
+}
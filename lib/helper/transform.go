@@ -1,6 +1,7 @@
 package helper
 
 import (
+	"errors"
 	"fmt"
 	"slices"
 	"sort"
@@ -256,3 +257,130 @@ func CombineFavorites(localFavs []structs.Favorite, upstreamFavs []structs.Favor
 
 	return result.All, &result, nil
 }
+
+// SameFavoriteTarget reports whether two favorites use the same account,
+// cloud access role, and access type, treating an unset access type as cli.
+func SameFavoriteTarget(a structs.Favorite, b structs.Favorite) bool {
+	accessType := func(f structs.Favorite) string {
+		if f.AccessType == "" {
+			return "cli"
+		}
+		return f.AccessType
+	}
+	return a.Account == b.Account && a.CAR == b.CAR && accessType(a) == accessType(b)
+}
+
+// MergeFavorites merges favorites pulled from Kion into local favorites. A
+// pulled favorite replaces the local favorites with the same name or the same
+// account, cloud access role, and access type, in place of the first one,
+// keeping the fields Kion doesn't store, like service, region, and firefox
+// container, from the local favorite. Others are appended.
+func MergeFavorites(localFavs []structs.Favorite, pulledFavs []structs.Favorite) []structs.Favorite {
+	merged := slices.Clone(localFavs)
+	for _, pulled := range pulledFavs {
+		matches := func(f structs.Favorite) bool {
+			return f.Name == pulled.Name || SameFavoriteTarget(f, pulled)
+		}
+
+		index := slices.IndexFunc(merged, matches)
+		if index < 0 {
+			merged = append(merged, pulled)
+			continue
+		}
+
+		// keep what only the local favorite knows
+		local := merged[index]
+		if local.Region != "" {
+			pulled.Region = local.Region
+		}
+		pulled.Service = local.Service
+		pulled.FirefoxContainerName = local.FirefoxContainerName
+		merged[index] = pulled
+
+		// drop any other local favorite the pulled one replaces
+		merged = append(merged[:index+1], slices.DeleteFunc(merged[index+1:], matches)...)
+	}
+	return merged
+}
+
+// AddImportedFavorites adds the favorites chosen from an import to local
+// favorites. A chosen favorite replaces the local favorites with the same name
+// or the same account, cloud access role, and access type, except local
+// favorites the import holds as they are. Chosen favorites are appended.
+func AddImportedFavorites(localFavs []structs.Favorite, chosenFavs []structs.Favorite, importedFavs []structs.Favorite) []structs.Favorite {
+	inImport := func(f structs.Favorite) bool {
+		return slices.ContainsFunc(importedFavs, func(i structs.Favorite) bool {
+			return i.Name == f.Name && SameFavoriteTarget(i, f)
+		})
+	}
+
+	added := slices.Clone(localFavs)
+	for _, chosen := range chosenFavs {
+		added = slices.DeleteFunc(added, func(f structs.Favorite) bool {
+			return !inImport(f) && (f.Name == chosen.Name || SameFavoriteTarget(f, chosen))
+		})
+	}
+	return append(added, chosenFavs...)
+}
+
+// FavoriteSubcommands are names that reach a favorite subcommand instead of a
+// favorite, so they can't be used as favorite names.
+var FavoriteSubcommands = []string{"list", "add", "remove", "rm", "delete", "rename", "edit", "export", "import"}
+
+// FavoriteFieldFlags are the flags that set fields of a favorite, see
+// SetFavoriteFields.
+var FavoriteFieldFlags = []string{"account", "car", "access-type", "region", "service", "firefox-container"}
+
+// ValidateFavoriteName returns an error if a name can't be used for a new
+// favorite alongside the given favorites.
+func ValidateFavoriteName(name string, favorites []structs.Favorite) error {
+	switch {
+	case name == "":
+		return errors.New("a favorite name is required")
+	case slices.Contains(FavoriteSubcommands, name):
+		return fmt.Errorf("%q is reserved for the favorite %v subcommand", name, name)
+	case slices.ContainsFunc(favorites, func(f structs.Favorite) bool { return f.Name == name }):
+		return fmt.Errorf("a favorite named %q already exists", name)
+	}
+	return nil
+}
+
+// FindFavorite returns the index of the aliased favorite with the given name,
+// -1 if there is none.
+func FindFavorite(favorites []structs.Favorite, name string) int {
+	return slices.IndexFunc(favorites, func(f structs.Favorite) bool { return f.Name == name && !f.Unaliased })
+}
+
+// SetFavoriteFields sets the fields of a favorite given by the names of the
+// flags that set them, see FavoriteFieldFlags.
+func SetFavoriteFields(favorite *structs.Favorite, fields map[string]string) {
+	for name, value := range fields {
+		switch name {
+		case "account":
+			favorite.Account = value
+		case "car":
+			favorite.CAR = value
+		case "access-type":
+			favorite.AccessType = value
+		case "region":
+			favorite.Region = value
+		case "service":
+			favorite.Service = value
+		case "firefox-container":
+			favorite.FirefoxContainerName = value
+		}
+	}
+}
+
+// ProfileFavorites returns the favorites of the named profile, or the top
+// level favorites if no profile is named.
+func ProfileFavorites(config structs.Configuration, profileName string) ([]structs.Favorite, error) {
+	if profileName == "" {
+		return config.Favorites, nil
+	}
+	profile, found := config.Profiles[profileName]
+	if !found {
+		return nil, fmt.Errorf("profile not found: %s", profileName)
+	}
+	return profile.Favorites, nil
+}
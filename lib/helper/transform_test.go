@@ -3,6 +3,7 @@ package helper
 import (
 	"fmt"
 	"reflect"
+	"slices"
 	"testing"
 
 	"github.com/kionsoftware/kion-cli/lib/kion"
@@ -246,3 +247,236 @@ func TestFindCARByName(t *testing.T) {
 		})
 	}
 }
+
+func TestMergeFavorites(t *testing.T) {
+	local := []structs.Favorite{
+		{Name: "sandbox", Account: "111111111111", CAR: "Admin", AccessType: "web", Service: "ec2", FirefoxContainerName: "sb", Region: "us-east-1"},
+		{Name: "prod", Account: "222222222222", CAR: "ReadOnly", AccessType: "cli"},
+		{Name: "prod-ro", Account: "333333333333", CAR: "ReadOnly"},
+		{Name: "dev", Account: "444444444444", CAR: "Admin", AccessType: "cli"},
+	}
+	pulled := []structs.Favorite{
+		// same target, new name
+		{Name: "sb", Account: "111111111111", CAR: "Admin", AccessType: "web", Region: "us-west-2"},
+		// same name as one local favorite and same target as another
+		{Name: "prod", Account: "333333333333", CAR: "ReadOnly", AccessType: "cli"},
+		// new
+		{Name: "qa", Account: "555555555555", CAR: "Admin", AccessType: "cli", Region: "eu-west-1"},
+	}
+
+	want := []structs.Favorite{
+		{Name: "sb", Account: "111111111111", CAR: "Admin", AccessType: "web", Service: "ec2", FirefoxContainerName: "sb", Region: "us-east-1"},
+		{Name: "prod", Account: "333333333333", CAR: "ReadOnly", AccessType: "cli"},
+		{Name: "dev", Account: "444444444444", CAR: "Admin", AccessType: "cli"},
+		{Name: "qa", Account: "555555555555", CAR: "Admin", AccessType: "cli", Region: "eu-west-1"},
+	}
+	got := MergeFavorites(local, pulled)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("\ngot:\n  %v\nwanted:\n  %v", got, want)
+	}
+	if local[0].Name != "sandbox" {
+		t.Errorf("local favorites were modified")
+	}
+}
+
+func TestAddImportedFavorites(t *testing.T) {
+	prod := structs.Favorite{Name: "prod", Account: "222222222222", CAR: "ReadOnly", AccessType: "cli", Region: "us-east-1"}
+	sandbox := structs.Favorite{Name: "sandbox", Account: "111111111111", CAR: "Admin", AccessType: "web"}
+
+	tests := []struct {
+		name     string
+		local    []structs.Favorite
+		chosen   []structs.Favorite
+		imported []structs.Favorite
+		want     []structs.Favorite
+	}{
+		{
+			"New",
+			[]structs.Favorite{prod},
+			[]structs.Favorite{sandbox},
+			[]structs.Favorite{sandbox},
+			[]structs.Favorite{prod, sandbox},
+		},
+		{
+			"ReplacesSameName",
+			[]structs.Favorite{prod, sandbox},
+			[]structs.Favorite{{Name: "prod", Account: "333333333333", CAR: "Admin", AccessType: "cli"}},
+			[]structs.Favorite{{Name: "prod", Account: "333333333333", CAR: "Admin", AccessType: "cli"}},
+			[]structs.Favorite{sandbox, {Name: "prod", Account: "333333333333", CAR: "Admin", AccessType: "cli"}},
+		},
+		{
+			"ReplacesSameTarget",
+			[]structs.Favorite{prod, sandbox},
+			[]structs.Favorite{{Name: "sb", Account: "111111111111", CAR: "Admin", AccessType: "web"}},
+			[]structs.Favorite{{Name: "sb", Account: "111111111111", CAR: "Admin", AccessType: "web"}},
+			[]structs.Favorite{prod, {Name: "sb", Account: "111111111111", CAR: "Admin", AccessType: "web"}},
+		},
+		{
+			"KeepsExactMatchesInImport",
+			[]structs.Favorite{prod},
+			[]structs.Favorite{{Name: "prod-ro", Account: "222222222222", CAR: "ReadOnly", AccessType: "cli"}},
+			[]structs.Favorite{prod, {Name: "prod-ro", Account: "222222222222", CAR: "ReadOnly", AccessType: "cli"}},
+			[]structs.Favorite{prod, {Name: "prod-ro", Account: "222222222222", CAR: "ReadOnly", AccessType: "cli"}},
+		},
+		{
+			"UnsetAccessTypeIsCLI",
+			[]structs.Favorite{{Name: "prod-old", Account: "222222222222", CAR: "ReadOnly"}},
+			[]structs.Favorite{prod},
+			[]structs.Favorite{prod},
+			[]structs.Favorite{prod},
+		},
+		{
+			"NothingChosen",
+			[]structs.Favorite{prod},
+			nil,
+			[]structs.Favorite{sandbox},
+			[]structs.Favorite{prod},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			local := slices.Clone(test.local)
+			got := AddImportedFavorites(local, test.chosen, test.imported)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("\ngot:\n  %v\nwanted:\n  %v", got, test.want)
+			}
+			if !reflect.DeepEqual(local, test.local) {
+				t.Errorf("local favorites were modified")
+			}
+		})
+	}
+}
+
+func TestValidateFavoriteName(t *testing.T) {
+	favorites := []structs.Favorite{
+		{Name: "prod", Account: "222222222222", CAR: "ReadOnly"},
+		{Name: "[unaliased]", Account: "333333333333", CAR: "Admin", Unaliased: true},
+	}
+
+	tests := []struct {
+		name    string
+		fav     string
+		wantErr bool
+	}{
+		{"New", "dev", false},
+		{"Empty", "", true},
+		{"Reserved", "list", true},
+		{"ReservedAlias", "rm", true},
+		{"Duplicate", "prod", true},
+		{"SimilarName", "prod-ro", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateFavoriteName(test.fav, favorites)
+			if (err != nil) != test.wantErr {
+				t.Errorf("got error %v, wanted error: %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestFindFavorite(t *testing.T) {
+	favorites := []structs.Favorite{
+		{Name: "prod", Account: "222222222222", CAR: "ReadOnly"},
+		{Name: "sandbox", Account: "333333333333", CAR: "Admin", Unaliased: true},
+		{Name: "dev", Account: "444444444444", CAR: "Admin"},
+	}
+
+	tests := []struct {
+		name string
+		fav  string
+		want int
+	}{
+		{"First", "prod", 0},
+		{"Last", "dev", 2},
+		{"Unaliased", "sandbox", -1},
+		{"Missing", "qa", -1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := FindFavorite(favorites, test.fav); got != test.want {
+				t.Errorf("got %d, wanted %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSetFavoriteFields(t *testing.T) {
+	base := structs.Favorite{Name: "prod", Account: "222222222222", CAR: "ReadOnly", AccessType: "cli", Region: "us-east-1"}
+
+	tests := []struct {
+		name   string
+		fields map[string]string
+		want   structs.Favorite
+	}{
+		{
+			"None",
+			map[string]string{},
+			base,
+		},
+		{
+			"All",
+			map[string]string{"account": "333333333333", "car": "Admin", "access-type": "web", "region": "us-west-2", "service": "ec2", "firefox-container": "work"},
+			structs.Favorite{Name: "prod", Account: "333333333333", CAR: "Admin", AccessType: "web", Region: "us-west-2", Service: "ec2", FirefoxContainerName: "work"},
+		},
+		{
+			"ClearsRegion",
+			map[string]string{"region": ""},
+			structs.Favorite{Name: "prod", Account: "222222222222", CAR: "ReadOnly", AccessType: "cli"},
+		},
+		{
+			"IgnoresUnknown",
+			map[string]string{"name": "other"},
+			base,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := base
+			SetFavoriteFields(&got, test.fields)
+			if got != test.want {
+				t.Errorf("\ngot:\n  %v\nwanted:\n  %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestProfileFavorites(t *testing.T) {
+	top := []structs.Favorite{{Name: "prod", Account: "222222222222", CAR: "ReadOnly"}}
+	work := []structs.Favorite{{Name: "dev", Account: "444444444444", CAR: "Admin"}}
+	config := structs.Configuration{
+		Favorites: top,
+		Profiles: map[string]structs.Profile{
+			"work":  {Favorites: work},
+			"empty": {},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		profile string
+		want    []structs.Favorite
+		wantErr bool
+	}{
+		{"TopLevel", "", top, false},
+		{"Profile", "work", work, false},
+		{"EmptyProfile", "empty", nil, false},
+		{"MissingProfile", "nope", nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ProfileFavorites(config, test.profile)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, wanted error: %v", err, test.wantErr)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("\ngot:\n  %v\nwanted:\n  %v", got, test.want)
+			}
+		})
+	}
+}
@@ -18,9 +18,9 @@ import (
 // the user selected Cloud Access Role. Optional account number and or car name
 // can be passed via an existing car struct, the flow will dynamically ask what
 // is needed to be able to find the full car.
-func CARSelector(cCtx *cli.Context, car *kion.CAR) error {
+func CARSelector(cCtx *cli.Context, client *kion.Client, car *kion.CAR) error {
 	// get list of projects, then build list of names and lookup map
-	projects, err := kion.GetProjects(cCtx.String("endpoint"), cCtx.String("token"))
+	projects, err := client.GetProjects(cCtx.Context)
 	if err != nil {
 		return err
 	}
@@ -40,7 +40,7 @@ func CARSelector(cCtx *cli.Context, car *kion.CAR) error {
 		// give us one full support line of buffer
 
 		// get all cars for authed user, works with min permission set
-		cars, err := kion.GetCARS(cCtx.String("endpoint"), cCtx.String("token"), "")
+		cars, err := client.GetCARS(cCtx.Context, "")
 		if err != nil {
 			return err
 		}
@@ -87,11 +87,11 @@ func CARSelector(cCtx *cli.Context, car *kion.CAR) error {
 		return nil
 	} else {
 		// get list of accounts on project, then build a list of names and lookup map
-		accounts, statusCode, err := kion.GetAccountsOnProject(cCtx.String("endpoint"), cCtx.String("token"), pMap[project].ID)
+		accounts, err := client.GetAccountsOnProject(cCtx.Context, pMap[project].ID)
 		if err != nil {
-			if statusCode == 403 {
+			if kion.IsStatus(err, 403) {
 				// if we're getting a 403 work around permissions bug by temp using private api
-				return carSelectorPrivateAPI(cCtx, pMap, project, car)
+				return carSelectorPrivateAPI(cCtx, client, pMap, project, car)
 			} else {
 				return err
 			}
@@ -108,7 +108,7 @@ func CARSelector(cCtx *cli.Context, car *kion.CAR) error {
 		}
 
 		// get a list of cloud access roles, then build a list of names and lookup map
-		cars, err := kion.GetCARSOnProject(cCtx.String("endpoint"), cCtx.String("token"), pMap[project].ID, aMap[account].ID)
+		cars, err := client.GetCARSOnProject(cCtx.Context, pMap[project].ID, aMap[account].ID)
 		if err != nil {
 			return err
 		}
@@ -141,9 +141,9 @@ func CARSelector(cCtx *cli.Context, car *kion.CAR) error {
 // carSelectorPrivateAPI is a temp shim workaround to address a public API
 // permissions issue. CARSelector should be called directly which will the
 // forward to this function if needed.
-func carSelectorPrivateAPI(cCtx *cli.Context, pMap map[string]kion.Project, project string, car *kion.CAR) error {
+func carSelectorPrivateAPI(cCtx *cli.Context, client *kion.Client, pMap map[string]kion.Project, project string, car *kion.CAR) error {
 	// hit private api endpoint to gather all users cars and their associated accounts
-	caCARs, err := kion.GetConsoleAccessCARS(cCtx.String("endpoint"), cCtx.String("token"), pMap[project].ID)
+	caCARs, err := client.GetConsoleAccessCARS(cCtx.Context, pMap[project].ID)
 	if err != nil {
 		return err
 	}
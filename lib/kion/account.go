@@ -1,6 +1,7 @@
 package kion
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -30,43 +31,43 @@ type Account struct {
 
 // GetAccountsOnProject returns a list of Accounts associated with a given Kion
 // project.
-func GetAccountsOnProject(host string, token string, id uint) ([]Account, int, error) {
+func (c *Client) GetAccountsOnProject(ctx context.Context, id uint) ([]Account, error) {
 	// build our query and get response
-	url := fmt.Sprintf("%v/api/v3/project/%v/accounts", host, id)
+	path := fmt.Sprintf("/api/v3/project/%v/accounts", id)
 	query := map[string]string{}
 	var data any
-	resp, statusCode, err := runQuery("GET", url, token, query, data)
+	resp, err := c.runQuery(ctx, "GET", path, query, data)
 	if err != nil {
-		return nil, statusCode, err
+		return nil, err
 	}
 
 	// unmarshal response body
 	var accounts []Account
-	jsonErr := json.Unmarshal(resp.Data, &accounts)
-	if jsonErr != nil {
-		return nil, 0, err
+	err = json.Unmarshal(resp.Data, &accounts)
+	if err != nil {
+		return nil, err
 	}
 
-	return accounts, resp.Status, nil
+	return accounts, nil
 }
 
 // GetAccount returns an account by the given account number.
-func GetAccount(host string, token string, accountNum string) (*Account, int, error) {
+func (c *Client) GetAccount(ctx context.Context, accountNum string) (*Account, error) {
 	// build our query and get response
-	url := fmt.Sprintf("%v/api/v3/account/by-account-number/%v", host, accountNum)
+	path := fmt.Sprintf("/api/v3/account/by-account-number/%v", accountNum)
 	query := map[string]string{}
 	var data any
-	resp, statusCode, err := runQuery("GET", url, token, query, data)
+	resp, err := c.runQuery(ctx, "GET", path, query, data)
 	if err != nil {
-		return nil, statusCode, err
+		return nil, err
 	}
 
 	// unmarshal response body
 	var account Account
-	jsonErr := json.Unmarshal(resp.Data, &account)
-	if jsonErr != nil {
-		return nil, 0, err
+	err = json.Unmarshal(resp.Data, &account)
+	if err != nil {
+		return nil, err
 	}
 
-	return &account, resp.Status, nil
+	return &account, nil
 }
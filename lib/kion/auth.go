@@ -1,10 +1,16 @@
 package kion
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"time"
 )
 
+// TokenProbeTTL is how long a cached session that Kion accepted is trusted
+// before it is probed again.
+var TokenProbeTTL = 5 * time.Minute
+
 ////////////////////////////////////////////////////////////////////////////////
 //                                                                            //
 //  Auth                                                                      //
@@ -14,8 +20,12 @@ import (
 // Session maps to the session data returned by Kion after authentication.
 type Session struct {
 	// ID       int `json:"id"`
+	Host     string
 	IDMSID   uint
 	UserName string
+	// Probed is when Kion last accepted the access token, so cached sessions
+	// are only probed again once TokenProbeTTL has passed.
+	Probed time.Time
 	// UserID   int `json:"user_id"`
 	Access struct {
 		Expiry string `json:"expiry"`
@@ -44,14 +54,20 @@ type AuthRequest struct {
 	Password string `json:"password"`
 }
 
+// RefreshRequest maps to the required post body when exchanging a refresh
+// token for a new session.
+type RefreshRequest struct {
+	Key string `json:"key"`
+}
+
 // GetIDMSs queries the Kion API for all configured IDMS systems with which a
 // user can authenticate via username and password.
-func GetIDMSs(host string) ([]IDMS, error) {
+func (c *Client) GetIDMSs(ctx context.Context) ([]IDMS, error) {
 	// build our query and get response
-	url := fmt.Sprintf("%v/api/v2/idms", host)
+	path := "/api/v2/idms"
 	query := map[string]string{}
 	var data any
-	resp, _, err := runQuery("GET", url, "", query, data)
+	resp, err := c.runQuery(ctx, "GET", path, query, data)
 	if err != nil {
 		return nil, err
 	}
@@ -76,16 +92,16 @@ func GetIDMSs(host string) ([]IDMS, error) {
 
 // Authenticate queries the Kion API to authenticate a user via username and
 // password.
-func Authenticate(host string, idmsID uint, un string, pw string) (Session, error) {
+func (c *Client) Authenticate(ctx context.Context, idmsID uint, un string, pw string) (Session, error) {
 	// build our query and get response
-	url := fmt.Sprintf("%v/api/v3/token", host)
+	path := "/api/v3/token"
 	query := map[string]string{}
 	data := AuthRequest{
 		IDMSID:   idmsID,
 		Username: un,
 		Password: pw,
 	}
-	resp, _, err := runQuery("POST", url, "", query, data)
+	resp, err := c.runQuery(ctx, "POST", path, query, data)
 	if err != nil {
 		return Session{}, err
 	}
@@ -99,3 +115,53 @@ func Authenticate(host string, idmsID uint, un string, pw string) (Session, erro
 
 	return session, nil
 }
+
+// RefreshSession queries the Kion API to exchange a refresh token for a new
+// session. The returned session carries a new access token and, if provided
+// by Kion, a new refresh token.
+func (c *Client) RefreshSession(ctx context.Context, refreshToken string) (Session, error) {
+	// build our query and get response
+	path := "/api/v3/token/refresh"
+	query := map[string]string{}
+	data := RefreshRequest{
+		Key: refreshToken,
+	}
+	resp, err := c.runQuery(ctx, "POST", path, query, data)
+	if err != nil {
+		return Session{}, err
+	}
+
+	// unmarshal response body
+	var session Session
+	err = json.Unmarshal(resp.Data, &session)
+	if err != nil {
+		return Session{}, err
+	}
+
+	return session, nil
+}
+
+// ValidateToken performs a lightweight request against the Kion API to
+// determine if a token is still accepted. Only a 401 is treated as an invalid
+// token, any other API response means the token authenticated. The token is
+// passed explicitly so a cached token can be checked before it is adopted by
+// the client.
+func (c *Client) ValidateToken(ctx context.Context, token string) (bool, error) {
+	// build our query and get response, the aws access config endpoint is small
+	// and reachable by all authenticated users even if it returns a 403
+	path := "/api/v3/app-config/aws-access"
+	query := map[string]string{}
+	var data any
+	probe := *c
+	probe.Token = token
+	_, err := probe.runQuery(ctx, "GET", path, query, data)
+	if IsStatus(err, 401) {
+		return false, nil
+	}
+	var apiErr *APIError
+	if err != nil && !errors.As(err, &apiErr) {
+		return false, err
+	}
+
+	return true, nil
+}
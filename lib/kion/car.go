@@ -1,6 +1,7 @@
 package kion
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -48,14 +49,14 @@ type CAR struct {
 
 // GetCARS queries the Kion API for all cloud access roles to which the
 // authenticated user has access. Deleted CARs will be excluded.
-func GetCARS(host string, token string, alias string) ([]CAR, error) {
+func (c *Client) GetCARS(ctx context.Context, alias string) ([]CAR, error) {
 	// build our query and get response
-	url := fmt.Sprintf("%v/api/v3/me/cloud-access-role", host)
+	path := "/api/v3/me/cloud-access-role"
 	query := map[string]string{
 		"account_alias": alias,
 	}
 	var data any
-	resp, _, err := runQuery("GET", url, token, query, data)
+	resp, err := c.runQuery(ctx, "GET", path, query, data)
 	if err != nil {
 		return nil, err
 	}
@@ -78,8 +79,8 @@ func GetCARS(host string, token string, alias string) ([]CAR, error) {
 }
 
 // GetCARSOnProject returns all cloud access roles that match a given project and account.
-func GetCARSOnProject(host string, token string, projID uint, accID uint) ([]CAR, error) {
-	allCars, err := GetCARS(host, token, "")
+func (c *Client) GetCARSOnProject(ctx context.Context, projID uint, accID uint) ([]CAR, error) {
+	allCars, err := c.GetCARS(ctx, "")
 	if err != nil {
 		return nil, err
 	}
@@ -96,8 +97,8 @@ func GetCARSOnProject(host string, token string, projID uint, accID uint) ([]CAR
 }
 
 // GetCARSOnAccount returns all cloud access roles that match a given account.
-func GetCARSOnAccount(host string, token string, accID uint) ([]CAR, error) {
-	allCars, err := GetCARS(host, token, "")
+func (c *Client) GetCARSOnAccount(ctx context.Context, accID uint) ([]CAR, error) {
+	allCars, err := c.GetCARS(ctx, "")
 	if err != nil {
 		return nil, err
 	}
@@ -118,8 +119,8 @@ func GetCARSOnAccount(host string, token string, accID uint) ([]CAR, error) {
 // against CARs with duplicate names, this function is kept as a convenience
 // and workaround for users on older version of Kion that have limited
 // permissions.
-func GetCARByName(host string, token string, carName string) (CAR, error) {
-	allCars, err := GetCARS(host, token, "")
+func (c *Client) GetCARByName(ctx context.Context, carName string) (CAR, error) {
+	allCars, err := c.GetCARS(ctx, "")
 	if err != nil {
 		return CAR{}, err
 	}
@@ -138,8 +139,8 @@ func GetCARByName(host string, token string, carName string) (CAR, error) {
 }
 
 // GetCARByNameAndAccount returns a car that matches by name and account number.
-func GetCARByNameAndAccount(host string, token string, carName string, accountNumber string) (CAR, error) {
-	allCars, err := GetCARS(host, token, "")
+func (c *Client) GetCARByNameAndAccount(ctx context.Context, carName string, accountNumber string) (CAR, error) {
+	allCars, err := c.GetCARS(ctx, "")
 	if err != nil {
 		return CAR{}, err
 	}
@@ -155,8 +156,8 @@ func GetCARByNameAndAccount(host string, token string, carName string, accountNu
 }
 
 // GetCARByNameAndAlias returns a car that matches by name and account alias.
-func GetCARByNameAndAlias(host string, token string, carName string, accountAlias string) (CAR, error) {
-	allCars, err := GetCARS(host, token, accountAlias)
+func (c *Client) GetCARByNameAndAlias(ctx context.Context, carName string, accountAlias string) (CAR, error) {
+	allCars, err := c.GetCARS(ctx, accountAlias)
 	if err != nil {
 		return CAR{}, err
 	}
@@ -172,8 +173,8 @@ func GetCARByNameAndAlias(host string, token string, carName string, accountAlia
 }
 
 // GetAllCARsByName returns a slice of cars that matches a given name.
-func GetAllCARsByName(host string, token string, carName string) ([]CAR, error) {
-	allCars, err := GetCARS(host, token, "")
+func (c *Client) GetAllCARsByName(ctx context.Context, carName string) ([]CAR, error) {
+	allCars, err := c.GetCARS(ctx, "")
 	if err != nil {
 		return nil, err
 	}
@@ -182,7 +183,7 @@ func GetAllCARsByName(host string, token string, carName string) ([]CAR, error)
 	var cars []CAR
 	for _, car := range allCars {
 		if car.Name == carName {
-			account, _, err := GetAccount(host, token, car.AccountNumber)
+			account, err := c.GetAccount(ctx, car.AccountNumber)
 			if err != nil {
 				// TODO: this may not be what we want to do here, kept as info level log
 				// fmt.Println("  unable to lookup an associated account:", car.AccountNumber)
@@ -1,8 +1,8 @@
 package kion
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 )
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -24,7 +24,7 @@ type URLRequest struct {
 }
 
 // GetFederationURL queries the Kion API to generate a federation URL.
-func GetFederationURL(host string, token string, car CAR) (string, error) {
+func (c *Client) GetFederationURL(ctx context.Context, car CAR) (string, error) {
 	// converting cloud access role type to role type
 	var roleType string
 	switch car.CloudAccessRoleType {
@@ -35,7 +35,7 @@ func GetFederationURL(host string, token string, car CAR) (string, error) {
 	}
 
 	// build our query and get response
-	url := fmt.Sprintf("%v/api/v1/console-access", host)
+	path := "/api/v1/console-access"
 	query := map[string]string{}
 	data := URLRequest{
 		AccountID:      car.AccountID,
@@ -46,7 +46,7 @@ func GetFederationURL(host string, token string, car CAR) (string, error) {
 		RoleID:         car.ID,
 		RoleType:       roleType,
 	}
-	resp, _, err := runQuery("POST", url, token, query, data)
+	resp, err := c.runQuery(ctx, "POST", path, query, data)
 	if err != nil {
 		return "", err
 	}
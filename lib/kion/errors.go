@@ -0,0 +1,108 @@
+package kion
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// requestIDHeaders are the response headers checked, in order, for an
+// identifier that can be used to find a request in server side logs.
+var requestIDHeaders = []string{"X-Request-Id", "X-Correlation-Id", "X-Amzn-Trace-Id"}
+
+// htmlTitle pulls the title out of an HTML error page.
+var htmlTitle = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// APIError is returned when Kion, or something in front of it, answers a
+// request with an unsuccessful status or an unreadable body. Use errors.As to
+// inspect it.
+type APIError struct {
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+
+	// Message is the message Kion returned, or a summary of the body when it
+	// was not a Kion API response.
+	Message string
+
+	// Method and Endpoint identify the request, the endpoint excludes the host
+	// and query string.
+	Method   string
+	Endpoint string
+
+	// RequestID identifies the request in server side logs, if one was sent.
+	RequestID string
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	detail := fmt.Sprintf("%s %s", e.Method, e.Endpoint)
+	if e.RequestID != "" {
+		detail += ", request id " + e.RequestID
+	}
+	return fmt.Sprintf("[%d] %s (%s)", e.StatusCode, msg, detail)
+}
+
+// IsStatus reports whether err is an APIError with the given status code.
+func IsStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// newAPIError builds an APIError for the response to a request. The message
+// is only used when the body could be parsed as a Kion API response,
+// otherwise the body is summarized.
+func newAPIError(method string, path string, resp *http.Response, body []byte, message string, parsed bool) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    message,
+		Method:     method,
+		Endpoint:   path,
+	}
+	for _, header := range requestIDHeaders {
+		if id := resp.Header.Get(header); id != "" {
+			apiErr.RequestID = id
+			break
+		}
+	}
+	if !parsed {
+		apiErr.Message = summarizeBody(resp.Header.Get("Content-Type"), body)
+	}
+	return apiErr
+}
+
+// summarizeBody describes a response body that was not a Kion API response,
+// such as an error page from a load balancer or proxy.
+func summarizeBody(contentType string, body []byte) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "" {
+		mediaType = http.DetectContentType(body)
+		mediaType, _, _ = mime.ParseMediaType(mediaType)
+	}
+
+	text := strings.TrimSpace(string(body))
+	if text == "" {
+		return "empty response body"
+	}
+
+	// html pages are best summed up by their title
+	if strings.Contains(mediaType, "html") {
+		if match := htmlTitle.FindStringSubmatch(text); len(match) > 1 {
+			text = strings.TrimSpace(match[1])
+		}
+	}
+
+	// keep it to a single readable line
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) > 200 {
+		text = text[:200] + "..."
+	}
+
+	return fmt.Sprintf("unexpected %s response: %s", mediaType, text)
+}
@@ -0,0 +1,124 @@
+package kion
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestClientAPIError(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		headers     map[string]string
+		wantMessage string
+		wantID      string
+	}{
+		{
+			"KionMessage",
+			403,
+			"application/json",
+			`{"status":403,"message":"insufficient permissions"}`,
+			map[string]string{"X-Request-Id": "abc-123"},
+			"insufficient permissions",
+			"abc-123",
+		},
+		{
+			"LoadBalancerPage",
+			502,
+			"text/html",
+			"<html><head><title>502 Bad Gateway</title></head><body><center><h1>502 Bad Gateway</h1></center></body></html>",
+			nil,
+			"unexpected text/html response: 502 Bad Gateway",
+			"",
+		},
+		{
+			"PlainText",
+			500,
+			"text/plain; charset=utf-8",
+			"upstream connect error\n  or disconnect",
+			nil,
+			"unexpected text/plain response: upstream connect error or disconnect",
+			"",
+		},
+		{
+			"EmptyBody",
+			404,
+			"",
+			"",
+			nil,
+			"empty response body",
+			"",
+		},
+		{
+			"SuccessWithoutJSON",
+			200,
+			"text/html",
+			"<html><title>Sign In</title></html>",
+			nil,
+			"unexpected text/html response: Sign In",
+			"",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newTestClient("abc123", func(req *http.Request) (*http.Response, error) {
+				resp := &http.Response{
+					StatusCode: test.status,
+					Header:     http.Header{},
+					Body:       io.NopCloser(bytes.NewBufferString(test.body)),
+				}
+				if test.contentType != "" {
+					resp.Header.Set("Content-Type", test.contentType)
+				}
+				for key, value := range test.headers {
+					resp.Header.Set(key, value)
+				}
+				return resp, nil
+			})
+			client.Retry = RetryPolicy{}
+
+			_, err := client.GetProjects(context.Background())
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("expected an *APIError, got %T: %v", err, err)
+			}
+			if apiErr.StatusCode != test.status {
+				t.Errorf("status: got %d, wanted %d", apiErr.StatusCode, test.status)
+			}
+			if apiErr.Message != test.wantMessage {
+				t.Errorf("message: got %q, wanted %q", apiErr.Message, test.wantMessage)
+			}
+			if apiErr.Method != "GET" || apiErr.Endpoint != "/api/v3/project" {
+				t.Errorf("endpoint: got %s %s", apiErr.Method, apiErr.Endpoint)
+			}
+			if apiErr.RequestID != test.wantID {
+				t.Errorf("request id: got %q, wanted %q", apiErr.RequestID, test.wantID)
+			}
+			if !IsStatus(err, test.status) {
+				t.Errorf("IsStatus did not match %d", test.status)
+			}
+		})
+	}
+}
+
+func TestAPIErrorWrapped(t *testing.T) {
+	client := newTestClient("abc123", func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(404, `{"status":404,"message":"alias not found"}`), nil
+	})
+
+	err := client.DeleteFavorite(context.Background(), "prod")
+	if !IsStatus(err, 404) {
+		t.Fatalf("expected a wrapped 404, got %v", err)
+	}
+	want := "failed to delete favorite with name prod: [404] alias not found (DELETE /api/v3/user-cloud-access-role-alias)"
+	if err.Error() != want {
+		t.Errorf("\ngot:\n  %s\nwanted:\n  %s", err.Error(), want)
+	}
+}
@@ -1,6 +1,7 @@
 package kion
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
@@ -20,21 +21,21 @@ type FavoritesResponse struct {
 }
 
 // GetAPIFavorites returns a list of a user's Favorites associated with a given Kion from the API
-func GetAPIFavorites(host string, token string) ([]structs.Favorite, int, error) {
+func (c *Client) GetAPIFavorites(ctx context.Context) ([]structs.Favorite, error) {
 
-	url := fmt.Sprintf("%v/api/v3/user-cloud-access-role-alias", host)
+	path := "/api/v3/user-cloud-access-role-alias"
 	query := map[string]string{}
 	var data any
-	resp, statusCode, err := runQuery("GET", url, token, query, data)
+	resp, err := c.runQuery(ctx, "GET", path, query, data)
 	if err != nil {
-		return nil, statusCode, err
+		return nil, err
 	}
 
 	// unmarshal response body
 	var favorites []structs.Favorite
-	jsonErr := json.Unmarshal(resp.Data, &favorites)
-	if jsonErr != nil {
-		return nil, 0, err
+	err = json.Unmarshal(resp.Data, &favorites)
+	if err != nil {
+		return nil, err
 	}
 
 	var apiFavorites []structs.Favorite
@@ -51,11 +52,11 @@ func GetAPIFavorites(host string, token string) ([]structs.Favorite, int, error)
 		apiFavorites = append(apiFavorites, apiFav)
 	}
 
-	return apiFavorites, resp.Status, nil
+	return apiFavorites, nil
 }
 
-func CreateFavorite(host string, token string, favorite structs.Favorite) (structs.Favorite, int, error) {
-	url := fmt.Sprintf("%v/api/v3/user-cloud-access-role-alias", host)
+func (c *Client) CreateFavorite(ctx context.Context, favorite structs.Favorite) (structs.Favorite, error) {
+	path := "/api/v3/user-cloud-access-role-alias"
 	query := map[string]string{}
 	data := map[string]string{
 		"alias_name":             favorite.Name,
@@ -63,39 +64,29 @@ func CreateFavorite(host string, token string, favorite structs.Favorite) (struc
 		"cloud_access_role_name": favorite.CAR,
 		"access_type":            favorite.AccessType,
 	}
-	resp, statusCode, err := runQuery("POST", url, token, query, data)
+	resp, err := c.runQuery(ctx, "POST", path, query, data)
 	if err != nil {
-		return structs.Favorite{}, statusCode, err
+		return structs.Favorite{}, fmt.Errorf("failed to create favorite: %w", err)
 	}
 
 	// unmarshal response body
 	var createdFav structs.Favorite
-	jsonErr := json.Unmarshal(resp.Data, &createdFav)
-	if jsonErr != nil {
-		return structs.Favorite{}, 0, jsonErr
-	}
-
-	// check if the response is successful
-	if statusCode != 201 && statusCode != 200 {
-		return structs.Favorite{}, statusCode, fmt.Errorf("failed to create favorite: %s", resp.Message)
+	err = json.Unmarshal(resp.Data, &createdFav)
+	if err != nil {
+		return structs.Favorite{}, err
 	}
 
-	return createdFav, statusCode, nil
+	return createdFav, nil
 }
 
-func DeleteFavorite(host string, token string, favoriteName string) (int, error) {
-	url := fmt.Sprintf("%v/api/v3/user-cloud-access-role-alias", host)
+func (c *Client) DeleteFavorite(ctx context.Context, favoriteName string) error {
+	path := "/api/v3/user-cloud-access-role-alias"
 	query := map[string]string{}
 	data := map[string]string{"alias_name": favoriteName}
-	resp, statusCode, err := runQuery("DELETE", url, token, query, data)
+	_, err := c.runQuery(ctx, "DELETE", path, query, data)
 	if err != nil {
-		return statusCode, err
-	}
-
-	// check if the response is successful
-	if statusCode != 200 {
-		return statusCode, fmt.Errorf("failed to delete favorite with name %s: %s", favoriteName, resp.Message)
+		return fmt.Errorf("failed to delete favorite with name %s: %w", favoriteName, err)
 	}
 
-	return statusCode, nil
+	return nil
 }
@@ -3,40 +3,165 @@ package kion
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
 	"io"
 	"net/http"
 	"strings"
 )
 
+// DefaultUserAgent is the user agent sent with requests unless the client is
+// configured with another.
+const DefaultUserAgent = "kion-cli"
+
 type APIRespBody struct {
 	Status  int             `json:"status"`
 	Message string          `json:"message"`
 	Data    json.RawMessage `json:"data"`
 }
 
+////////////////////////////////////////////////////////////////////////////////
+//                                                                            //
+//  Client                                                                    //
+//                                                                            //
+////////////////////////////////////////////////////////////////////////////////
+
+// Client holds what is needed to interact with an instance of Kion. A single
+// Client should be reused across calls so the underlying HTTP client can pool
+// connections.
+type Client struct {
+	// BaseURL is the URL of the targeted Kion instance.
+	BaseURL string
+
+	// Token is the API key or bearer token used to authenticate requests.
+	Token string
+
+	// HTTPClient is used to send all requests, swap its Transport to change
+	// how requests are sent.
+	HTTPClient *http.Client
+
+	// UserAgent identifies the caller to Kion.
+	UserAgent string
+
+	// Retry controls how requests that fail for transient reasons are retried.
+	Retry RetryPolicy
+
+	// BeforeRequest hooks are called with every API request before it is sent.
+	BeforeRequest []func(req *http.Request)
+
+	// AfterResponse hooks are called with every API response after it is
+	// received.
+	AfterResponse []func(resp *http.Response)
+}
+
+// NewClient creates a new Client for the Kion instance at baseURL using the
+// provided token for authentication.
+func NewClient(baseURL string, token string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{},
+		UserAgent:  DefaultUserAgent,
+		Retry:      DefaultRetryPolicy(),
+	}
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 //                                                                            //
 //  Helpers                                                                   //
 //                                                                            //
 ////////////////////////////////////////////////////////////////////////////////
 
-// runQuery performs queries against the Kion API.
-func runQuery(method string, url string, token string, query map[string]string, payload any) (APIRespBody, int, error) {
+// httpClient returns the HTTP client requests should be sent with.
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+// browserClient returns an HTTP client that shares the transport of the
+// Client but does not follow redirects, as needed when walking through login
+// flows that expect a browser.
+func (c *Client) browserClient() *http.Client {
+	return &http.Client{
+		Transport: c.httpClient().Transport,
+		Timeout:   c.httpClient().Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// runQuery performs queries against the Kion API. Idempotent requests are
+// retried on transient failures according to the clients retry policy.
+// Unsuccessful responses are returned as an *APIError.
+func (c *Client) runQuery(ctx context.Context, method string, path string, query map[string]string, payload any) (APIRespBody, error) {
+	return c.runQueryWithRetry(ctx, retryModeFor(method), method, path, query, payload)
+}
+
+// runQueryWithRetry performs queries against the Kion API, retrying failed
+// attempts as allowed by the given retry mode.
+func (c *Client) runQueryWithRetry(ctx context.Context, mode retryMode, method string, path string, query map[string]string, payload any) (APIRespBody, error) {
 	// prepare our response struct
 	apiResp := APIRespBody{}
 
 	// prepare the request body
 	reqBody, err := json.Marshal(payload)
 	if err != nil {
-		return apiResp, 0, err
+		return apiResp, err
 	}
 
+	// send the request until it succeeds or we run out of attempts
+	var resp *http.Response
+	var respBody []byte
+	for attempt := 1; ; attempt++ {
+		resp, respBody, err = c.send(ctx, method, path, query, reqBody)
+		status := 0
+		var header http.Header
+		if resp != nil {
+			status = resp.StatusCode
+			header = resp.Header
+		}
+		if !shouldRetry(ctx, mode, status, err) {
+			break
+		}
+		wait, again := c.Retry.delay(attempt, header)
+		if !again {
+			break
+		}
+		if sleepErr := sleepContext(ctx, wait); sleepErr != nil {
+			return apiResp, sleepErr
+		}
+	}
+	if err != nil {
+		return apiResp, err
+	}
+
+	// a body that isn't json likely came from something in front of kion, like
+	// a load balancer, so report the status and what was sent instead
+	parsed := json.Unmarshal(respBody, &apiResp) == nil
+
+	// handle non 200's
+	if resp.StatusCode != 200 && resp.StatusCode != 201 {
+		return apiResp, newAPIError(method, path, resp, respBody, apiResp.Message, parsed)
+	}
+	if !parsed {
+		return apiResp, newAPIError(method, path, resp, respBody, "", false)
+	}
+
+	// return the response
+	return apiResp, nil
+}
+
+// send makes a single attempt at a request against the Kion API and returns
+// the response along with its fully read body. A nil response means no
+// response was received.
+func (c *Client) send(ctx context.Context, method string, path string, query map[string]string, reqBody []byte) (*http.Response, []byte, error) {
 	// start our request
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(reqBody))
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, bytes.NewReader(reqBody))
 	if err != nil {
-		return apiResp, 0, err
+		return nil, nil, err
 	}
 
 	// append on our parameters to the req.URL.String()
@@ -47,39 +172,40 @@ func runQuery(method string, url string, token string, query map[string]string,
 	req.URL.RawQuery = q.Encode()
 
 	// add authorization header to the req
-	if token != "" {
-		req.Header.Add("Authorization", "Bearer "+token)
+	if c.Token != "" {
+		req.Header.Add("Authorization", "Bearer "+c.Token)
 	}
 
 	// identify the source of the request
 	req.Header.Add("kion-source", "kion-cli")
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	// run any request hooks
+	for _, hook := range c.BeforeRequest {
+		hook(req)
+	}
 
 	// send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := c.httpClient().Do(req)
 	if err != nil {
-		return apiResp, 0, err
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
 
-	// get the body of the response
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return apiResp, 0, err
+	// run any response hooks
+	for _, hook := range c.AfterResponse {
+		hook(resp)
 	}
 
-	err = json.Unmarshal(respBody, &apiResp)
+	// get the body of the response, a failed read is a connection failure
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return apiResp, resp.StatusCode, err
-	}
-
-	// handle non 200's
-	if resp.StatusCode != 200 && resp.StatusCode != 201 {
-		return apiResp, resp.StatusCode, fmt.Errorf("[%v] %v", resp.StatusCode, apiResp.Message)
+		return nil, nil, err
 	}
 
-	// return the response
-	return apiResp, resp.StatusCode, nil
+	return resp, respBody, nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -89,11 +215,11 @@ func runQuery(method string, url string, token string, query map[string]string,
 ////////////////////////////////////////////////////////////////////////////////
 
 // GetVersion returns the targeted Kion's version number.
-func GetVersion(host string) (string, error) {
-	url := fmt.Sprintf("%v/api/version", host)
+func (c *Client) GetVersion(ctx context.Context) (string, error) {
+	path := "/api/version"
 	query := map[string]string{}
 	var data any
-	resp, _, err := runQuery("GET", url, "", query, data)
+	resp, err := c.runQuery(ctx, "GET", path, query, data)
 	if err != nil {
 		return "", err
 	}
@@ -114,13 +240,13 @@ func GetVersion(host string) (string, error) {
 // GetSessionDuration returns the AWS session duration configuration Kion uses
 // to generate session tokens. If 403 is received, we assume the shortest
 // setting of 15 minutes.
-func GetSessionDuration(host string, token string) (int, error) {
-	url := fmt.Sprintf("%v/api/v3/app-config/aws-access", host)
+func (c *Client) GetSessionDuration(ctx context.Context) (int, error) {
+	path := "/api/v3/app-config/aws-access"
 	query := map[string]string{}
 	var data any
-	resp, status, err := runQuery("GET", url, token, query, data)
+	resp, err := c.runQuery(ctx, "GET", path, query, data)
 	if err != nil {
-		if status == 403 {
+		if IsStatus(err, 403) {
 			return 15, nil
 		} else {
 			return 0, err
@@ -0,0 +1,138 @@
+package kion
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// roundTripFunc allows a plain function to be used as an http.RoundTripper.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// newTestClient returns a Client whose requests are answered by the provided
+// function instead of the network.
+func newTestClient(token string, fn roundTripFunc) *Client {
+	client := NewClient("https://kion.example.com/", token)
+	client.HTTPClient.Transport = fn
+	return client
+}
+
+// jsonResponse builds an http.Response with the given status and body.
+func jsonResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+	}
+}
+
+func TestClientRequest(t *testing.T) {
+	var got *http.Request
+	client := newTestClient("abc123", func(req *http.Request) (*http.Response, error) {
+		got = req
+		return jsonResponse(200, `{"status":200,"data":"3.14.2-dev"}`), nil
+	})
+	client.UserAgent = "kion-cli/test"
+
+	version, err := client.GetVersion(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if version != "3.14.2" {
+		t.Errorf("version: got %q, wanted %q", version, "3.14.2")
+	}
+	if got.URL.String() != "https://kion.example.com/api/version" {
+		t.Errorf("url: got %q", got.URL.String())
+	}
+	if auth := got.Header.Get("Authorization"); auth != "Bearer abc123" {
+		t.Errorf("authorization: got %q", auth)
+	}
+	if ua := got.Header.Get("User-Agent"); ua != "kion-cli/test" {
+		t.Errorf("user agent: got %q", ua)
+	}
+	if src := got.Header.Get("kion-source"); src != "kion-cli" {
+		t.Errorf("kion-source: got %q", src)
+	}
+}
+
+func TestClientHooks(t *testing.T) {
+	client := newTestClient("", func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("X-Test") != "set" {
+			t.Errorf("request hook did not run before the request was sent")
+		}
+		if req.Header.Get("Authorization") != "" {
+			t.Errorf("authorization header sent without a token")
+		}
+		return jsonResponse(200, `{"status":200,"data":"3.9.0"}`), nil
+	})
+
+	var statuses []int
+	client.BeforeRequest = append(client.BeforeRequest, func(req *http.Request) {
+		req.Header.Set("X-Test", "set")
+	})
+	client.AfterResponse = append(client.AfterResponse, func(resp *http.Response) {
+		statuses = append(statuses, resp.StatusCode)
+	})
+
+	if _, err := client.GetVersion(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(statuses) != 1 || statuses[0] != 200 {
+		t.Errorf("response hook: got %v, wanted [200]", statuses)
+	}
+}
+
+func TestClientSessionDurationForbidden(t *testing.T) {
+	client := newTestClient("abc123", func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(403, `{"status":403,"message":"forbidden"}`), nil
+	})
+
+	duration, err := client.GetSessionDuration(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if duration != 15 {
+		t.Errorf("duration: got %d, wanted 15", duration)
+	}
+}
+
+func TestClientTimeoutAndCancel(t *testing.T) {
+	// a kion that never answers until the test is done
+	release := make(chan struct{})
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer hung.Close()
+	defer close(release)
+
+	t.Run("Timeout", func(t *testing.T) {
+		client := NewClient(hung.URL, "abc123")
+		client.HTTPClient.Timeout = 50 * time.Millisecond
+		client.Retry = RetryPolicy{}
+
+		_, err := client.GetVersion(context.Background())
+		if err == nil {
+			t.Fatal("expected a timeout error, got nil")
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		client := NewClient(hung.URL, "abc123")
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		_, err := client.GetVersion(ctx)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	})
+}
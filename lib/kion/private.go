@@ -1,6 +1,7 @@
 package kion
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -20,12 +21,12 @@ type ConsoleAccessCAR struct {
 // GetConsoleAccessCARS hits the private API endpoint to gather all cloud
 // access roles a user has access to. This method should only be used as a
 // fallback.
-func GetConsoleAccessCARS(host string, token string, projID uint) ([]ConsoleAccessCAR, error) {
+func (c *Client) GetConsoleAccessCARS(ctx context.Context, projID uint) ([]ConsoleAccessCAR, error) {
 	// build our query and get response
-	url := fmt.Sprintf("%v/api/v1/project/%v/console-access", host, projID)
+	path := fmt.Sprintf("/api/v1/project/%v/console-access", projID)
 	query := map[string]string{}
 	var data any
-	resp, _, err := runQuery("GET", url, token, query, data)
+	resp, err := c.runQuery(ctx, "GET", path, query, data)
 	if err != nil {
 		return nil, err
 	}
@@ -1,6 +1,7 @@
 package kion
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -23,12 +24,12 @@ type Project struct {
 }
 
 // GetProject queries the Kion API for a list of all projects within the application.
-func GetProjects(host string, token string) ([]Project, error) {
+func (c *Client) GetProjects(ctx context.Context) ([]Project, error) {
 	// build our query and get response
-	url := host + "/api/v3/project"
+	path := "/api/v3/project"
 	query := map[string]string{}
 	var data any
-	resp, _, err := runQuery("GET", url, token, query, data)
+	resp, err := c.runQuery(ctx, "GET", path, query, data)
 	if err != nil {
 		return nil, err
 	}
@@ -47,12 +48,12 @@ func GetProjects(host string, token string) ([]Project, error) {
 // user has car access only to a project this will return a 403. To accommodate
 // users with minimal permissions test response codes and fallback accordingly
 // or use GetProjects which will work but be more verbose.
-func GetProjectByID(host string, token string, id uint) (Project, error) {
+func (c *Client) GetProjectByID(ctx context.Context, id uint) (Project, error) {
 	// build our query and get response
-	url := fmt.Sprintf("%v/api/v3/project/%v", host, id)
+	path := fmt.Sprintf("/api/v3/project/%v", id)
 	query := map[string]string{}
 	var data any
-	resp, _, err := runQuery("GET", url, token, query, data)
+	resp, err := c.runQuery(ctx, "GET", path, query, data)
 	if err != nil {
 		return Project{}, err
 	}
@@ -0,0 +1,195 @@
+package kion
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultRetryAttempts is the total number of attempts made for a request
+	// unless the client is configured otherwise.
+	DefaultRetryAttempts = 3
+
+	// DefaultRetryJitter is the fraction of each backoff delay that is
+	// randomized unless the client is configured otherwise.
+	DefaultRetryJitter = 0.2
+)
+
+// RetryPolicy controls how a Client retries requests that fail for transient
+// reasons, such as a dropped connection or an overloaded Kion instance.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts made for a request, values
+	// below 2 disable retries.
+	MaxAttempts int
+
+	// BaseDelay is the wait before the first retry, it doubles with each
+	// following attempt.
+	BaseDelay time.Duration
+
+	// MaxDelay caps the wait between attempts. A Retry-After header asking for
+	// a longer wait ends the retries instead.
+	MaxDelay time.Duration
+
+	// Jitter is the fraction, between 0 and 1, of each wait that is
+	// randomized so that many clients backing off at once spread out.
+	Jitter float64
+}
+
+// DefaultRetryPolicy returns the retry policy used by new clients.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: DefaultRetryAttempts,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+		Jitter:      DefaultRetryJitter,
+	}
+}
+
+// retryMode describes which failures a request may be retried on.
+type retryMode int
+
+const (
+	// retryNone never retries the request.
+	retryNone retryMode = iota
+
+	// retryConnection retries only when the connection or TLS handshake
+	// failed, so the request provably never reached Kion, used for calls that
+	// are not safe to repeat.
+	retryConnection
+
+	// retryTransient retries connection failures as well as responses that
+	// signal Kion is briefly unavailable.
+	retryTransient
+)
+
+// retryModeFor returns the retry mode for requests using the given method,
+// only idempotent methods are retried on transient responses.
+func retryModeFor(method string) retryMode {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return retryTransient
+	default:
+		return retryNone
+	}
+}
+
+// retryableStatus reports whether a response status signals a transient
+// failure worth retrying.
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// shouldRetry reports whether a failed attempt may be retried under the given
+// mode. A status of 0 with an error means no response was received.
+func shouldRetry(ctx context.Context, mode retryMode, status int, err error) bool {
+	// never retry once the caller has given up
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil && status == 0 {
+		switch mode {
+		case retryTransient:
+			return true
+		case retryConnection:
+			return requestNotSent(err)
+		default:
+			return false
+		}
+	}
+	return mode == retryTransient && retryableStatus(status)
+}
+
+// requestNotSent reports whether an error came from dialing Kion or the TLS
+// handshake, before any of the request was written. Timeouts and failures
+// reading the response are left out as Kion may have acted on the request.
+func requestNotSent(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	if errors.As(err, &recordErr) || errors.As(err, &alertErr) {
+		return true
+	}
+	// net/http doesn't export its handshake timeout error
+	return strings.Contains(err.Error(), "TLS handshake timeout")
+}
+
+// delay returns how long to wait before the next attempt, honoring any
+// Retry-After header sent by Kion. The bool is false if no further attempts
+// should be made.
+func (p RetryPolicy) delay(attempt int, header http.Header) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts {
+		return 0, false
+	}
+
+	// let kion tell us how long to back off
+	if wait, found := retryAfter(header, time.Now()); found {
+		if p.MaxDelay > 0 && wait > p.MaxDelay {
+			return 0, false
+		}
+		return wait, true
+	}
+
+	// exponential backoff capped at the max delay
+	wait := p.BaseDelay << (attempt - 1)
+	if p.MaxDelay > 0 && (wait > p.MaxDelay || wait <= 0) {
+		wait = p.MaxDelay
+	}
+
+	// spread out the wait by up to the jitter fraction in either direction
+	jitter := min(max(p.Jitter, 0), 1)
+	if jitter > 0 {
+		wait = time.Duration(float64(wait) * (1 + jitter*(2*rand.Float64()-1)))
+	}
+
+	return wait, true
+}
+
+// retryAfter parses a Retry-After header given either in seconds or as an
+// HTTP date.
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		return max(when.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// sleepContext waits for the given duration or until the context is done.
+func sleepContext(ctx context.Context, wait time.Duration) error {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,222 @@
+package kion
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// fastRetry is a retry policy that does not slow the tests down.
+var fastRetry = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Millisecond,
+	MaxDelay:    10 * time.Millisecond,
+}
+
+func TestClientRetry(t *testing.T) {
+	connErr := errors.New("connection reset by peer")
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	tests := []struct {
+		name      string
+		call      func(c *Client) error
+		responses []func() (*http.Response, error)
+		wantErr   bool
+		wantTries int
+	}{
+		{
+			"GetRetriesUnavailable",
+			func(c *Client) error { _, err := c.GetVersion(context.Background()); return err },
+			[]func() (*http.Response, error){
+				func() (*http.Response, error) { return jsonResponse(503, `{"status":503}`), nil },
+				func() (*http.Response, error) { return jsonResponse(200, `{"status":200,"data":"3.9.0"}`), nil },
+			},
+			false,
+			2,
+		},
+		{
+			"GetRetriesConnectionErrors",
+			func(c *Client) error { _, err := c.GetVersion(context.Background()); return err },
+			[]func() (*http.Response, error){
+				func() (*http.Response, error) { return nil, connErr },
+				func() (*http.Response, error) { return nil, connErr },
+				func() (*http.Response, error) { return jsonResponse(200, `{"status":200,"data":"3.9.0"}`), nil },
+			},
+			false,
+			3,
+		},
+		{
+			"GetGivesUpAfterMaxAttempts",
+			func(c *Client) error { _, err := c.GetVersion(context.Background()); return err },
+			[]func() (*http.Response, error){
+				func() (*http.Response, error) { return jsonResponse(502, `{"status":502}`), nil },
+				func() (*http.Response, error) { return jsonResponse(502, `{"status":502}`), nil },
+				func() (*http.Response, error) { return jsonResponse(502, `{"status":502}`), nil },
+			},
+			true,
+			3,
+		},
+		{
+			"GetDoesNotRetryClientErrors",
+			func(c *Client) error { _, err := c.GetVersion(context.Background()); return err },
+			[]func() (*http.Response, error){
+				func() (*http.Response, error) { return jsonResponse(404, `{"status":404}`), nil },
+			},
+			true,
+			1,
+		},
+		{
+			"STAKDoesNotRetryOverloaded",
+			func(c *Client) error {
+				_, err := c.GetSTAK(context.Background(), "car", "111122223333", "")
+				return err
+			},
+			[]func() (*http.Response, error){
+				func() (*http.Response, error) { return jsonResponse(503, `{"status":503}`), nil },
+			},
+			true,
+			1,
+		},
+		{
+			"STAKRetriesConnectionErrors",
+			func(c *Client) error {
+				_, err := c.GetSTAK(context.Background(), "car", "111122223333", "")
+				return err
+			},
+			[]func() (*http.Response, error){
+				func() (*http.Response, error) { return nil, dialErr },
+				func() (*http.Response, error) {
+					return jsonResponse(200, `{"status":200,"data":{"access_key":"a"}}`), nil
+				},
+			},
+			false,
+			2,
+		},
+		{
+			"STAKDoesNotRetryAfterSending",
+			func(c *Client) error {
+				_, err := c.GetSTAK(context.Background(), "car", "111122223333", "")
+				return err
+			},
+			[]func() (*http.Response, error){
+				func() (*http.Response, error) { return nil, connErr },
+			},
+			true,
+			1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tries := 0
+			client := newTestClient("abc123", func(req *http.Request) (*http.Response, error) {
+				if tries >= len(test.responses) {
+					t.Fatalf("unexpected attempt %d", tries+1)
+				}
+				tries++
+				return test.responses[tries-1]()
+			})
+			client.Retry = fastRetry
+
+			err := test.call(client)
+			if (err != nil) != test.wantErr {
+				t.Errorf("error: got %v, wanted error %v", err, test.wantErr)
+			}
+			if tries != test.wantTries {
+				t.Errorf("attempts: got %d, wanted %d", tries, test.wantTries)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		value     string
+		wantWait  time.Duration
+		wantFound bool
+	}{
+		{"Missing", "", 0, false},
+		{"Seconds", "7", 7 * time.Second, true},
+		{"Date", now.Add(3 * time.Second).Format(http.TimeFormat), 3 * time.Second, true},
+		{"PastDate", now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"Garbage", "soon", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header := http.Header{}
+			if test.value != "" {
+				header.Set("Retry-After", test.value)
+			}
+			wait, found := retryAfter(header, now)
+			if wait != test.wantWait || found != test.wantFound {
+				t.Errorf("got %v %v, wanted %v %v", wait, found, test.wantWait, test.wantFound)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 3 * time.Second}
+
+	// exponential backoff capped at the max delay
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		wait, again := policy.delay(attempt+1, nil)
+		if !again || wait != want {
+			t.Errorf("attempt %d: got %v %v, wanted %v true", attempt+1, wait, again, want)
+		}
+	}
+
+	// no attempts left
+	if _, again := policy.delay(5, nil); again {
+		t.Errorf("expected no further attempts after the max")
+	}
+
+	// retry-after longer than we're willing to wait
+	header := http.Header{"Retry-After": []string{"60"}}
+	if _, again := policy.delay(1, header); again {
+		t.Errorf("expected a long Retry-After to end retries")
+	}
+
+	// jitter stays within its bounds
+	policy.Jitter = 0.5
+	for range 100 {
+		wait, _ := policy.delay(1, nil)
+		if wait < 500*time.Millisecond || wait > 1500*time.Millisecond {
+			t.Fatalf("jittered wait out of bounds: %v", wait)
+		}
+	}
+}
+
+func TestRequestNotSent(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"Dial", &url.Error{Op: "Post", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}, true},
+		{"DNS", &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "kion.example"}}, true},
+		{"TLSAlert", &url.Error{Op: "Post", Err: tls.AlertError(40)}, true},
+		{"TLSTimeout", &url.Error{Op: "Post", Err: errors.New("net/http: TLS handshake timeout")}, true},
+		{"Read", &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}, false},
+		{"ClientTimeout", &url.Error{Op: "Post", Err: context.DeadlineExceeded}, false},
+		{"UnexpectedEOF", io.ErrUnexpectedEOF, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := requestNotSent(test.err); got != test.want {
+				t.Errorf("got %v, wanted %v", got, test.want)
+			}
+		})
+	}
+}
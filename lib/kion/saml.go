@@ -94,7 +94,7 @@ type SamlCallbackResult struct {
 	Err  error
 }
 
-func callExternalAuth(sp *saml2.SAMLServiceProvider, tokenChan chan SamlCallbackResult, printURL bool) (*AuthData, error) {
+func callExternalAuth(ctx context.Context, sp *saml2.SAMLServiceProvider, tokenChan chan SamlCallbackResult, printURL bool) (*AuthData, error) {
 	authURL, err := sp.BuildAuthURL("")
 	if err != nil {
 		log.Fatalf("The login info is invalid.\n %v", err)
@@ -107,17 +107,17 @@ func callExternalAuth(sp *saml2.SAMLServiceProvider, tokenChan chan SamlCallback
 	} else {
 		// define a context with 15 second timeout
 		var browserCommand *exec.Cmd
-		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		browserCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
 		defer cancel()
 
 		// identify command based on operating system
 		switch runtime.GOOS {
 		case "windows":
-			browserCommand = exec.CommandContext(ctx, "rundll32", "url.dll,FileProtocolHandler", authURL)
+			browserCommand = exec.CommandContext(browserCtx, "rundll32", "url.dll,FileProtocolHandler", authURL)
 		case "darwin":
-			browserCommand = exec.CommandContext(ctx, "open", authURL)
+			browserCommand = exec.CommandContext(browserCtx, "open", authURL)
 		case "linux":
-			browserCommand = exec.CommandContext(ctx, "xdg-open", authURL)
+			browserCommand = exec.CommandContext(browserCtx, "xdg-open", authURL)
 		default:
 			log.Println("Unsupported operating system:", runtime.GOOS)
 			return nil, fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
@@ -125,7 +125,7 @@ func callExternalAuth(sp *saml2.SAMLServiceProvider, tokenChan chan SamlCallback
 
 		// run the command to open the browser
 		err = browserCommand.Run()
-		if ctx.Err() == context.DeadlineExceeded {
+		if browserCtx.Err() == context.DeadlineExceeded {
 			log.Println("Timeout reached while trying to open the browser.")
 		} else if err != nil {
 			log.Println("Error opening browser:", err)
@@ -174,6 +174,22 @@ func callExternalAuth(sp *saml2.SAMLServiceProvider, tokenChan chan SamlCallback
 				Data: nil,
 				Err:  fmt.Errorf("authentication timed out after 60 seconds"),
 			}
+
+		case <-ctx.Done():
+			// the caller gave up, ie the user hit ctrl-c
+			timer.Stop()
+
+			// shut down the server
+			err := server.Shutdown(context.Background())
+			if err != nil {
+				log.Printf("Error shutting down server after cancellation: %v", err)
+			}
+
+			// send cancellation error
+			tokenChan <- SamlCallbackResult{
+				Data: nil,
+				Err:  fmt.Errorf("authentication canceled: %w", ctx.Err()),
+			}
 		}
 	}()
 
@@ -247,8 +263,9 @@ func validateSAMLMetadata(metadata *samlTypes.EntityDescriptor) error {
 	return nil
 }
 
-func AuthenticateSAML(appURL string, metadata *samlTypes.EntityDescriptor, serviceProviderIssuer string, printURL bool) (*AuthData, error) {
+func (c *Client) AuthenticateSAML(ctx context.Context, metadata *samlTypes.EntityDescriptor, serviceProviderIssuer string, printURL bool) (*AuthData, error) {
 	// Validate parameters
+	appURL := c.BaseURL
 	if appURL == "" {
 		return nil, fmt.Errorf("appUrl (Kion URL) is required but was empty")
 	}
@@ -318,14 +335,10 @@ func AuthenticateSAML(appURL string, metadata *samlTypes.EntityDescriptor, servi
 			return
 		}
 
-		client := &http.Client{
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				return http.ErrUseLastResponse
-			},
-		}
+		client := c.browserClient()
 
 		// get csrf token
-		csrfToken, csrfCookie, err := getCSRFToken(appURL, client)
+		csrfToken, csrfCookie, err := getCSRFToken(ctx, appURL, client)
 		if err != nil {
 			fmt.Println("error getting csrf token: ", csrfToken)
 			tokenChan <- SamlCallbackResult{Data: nil, Err: fmt.Errorf("error getting CSRF token: %s", csrfToken)}
@@ -346,7 +359,7 @@ func AuthenticateSAML(appURL string, metadata *samlTypes.EntityDescriptor, servi
 		jar.SetCookies(url, csrfCookie)
 		client.Jar = jar
 
-		r, err := http.NewRequest("POST", appURL+"/api/v1/saml/callback", bytes.NewReader(b))
+		r, err := http.NewRequestWithContext(ctx, "POST", appURL+"/api/v1/saml/callback", bytes.NewReader(b))
 		if err != nil {
 			rw.WriteHeader(http.StatusBadRequest)
 			tokenChan <- SamlCallbackResult{Data: nil, Err: fmt.Errorf("error creating SAML request: %w", err)}
@@ -381,7 +394,7 @@ func AuthenticateSAML(appURL string, metadata *samlTypes.EntityDescriptor, servi
 		ssoCode := groups[1]
 
 		// get auth and refresh token
-		authToken, refreshCookie, err := getAuthToken(appURL, ssoCode, csrfToken, client)
+		authToken, refreshCookie, err := getAuthToken(ctx, appURL, ssoCode, csrfToken, client)
 		if err != nil {
 			tokenChan <- SamlCallbackResult{Data: nil, Err: fmt.Errorf("failed to get auth token: %w", err)}
 			return
@@ -401,12 +414,13 @@ func AuthenticateSAML(appURL string, metadata *samlTypes.EntityDescriptor, servi
 		}, Err: nil}
 	})
 
-	return callExternalAuth(sp, tokenChan, printURL)
+	return callExternalAuth(ctx, sp, tokenChan, printURL)
 }
 
 // AuthenticateSAMLOld is the old version of AuthenticateSAML that does not use a cookie-based exchange.
-func AuthenticateSAMLOld(appURL string, metadata *samlTypes.EntityDescriptor, serviceProviderIssuer string, printURL bool) (*AuthData, error) {
+func (c *Client) AuthenticateSAMLOld(ctx context.Context, metadata *samlTypes.EntityDescriptor, serviceProviderIssuer string, printURL bool) (*AuthData, error) {
 	// Validate parameters
+	appURL := c.BaseURL
 	if appURL == "" {
 		return nil, fmt.Errorf("appUrl (Kion URL) is required but was empty")
 	}
@@ -476,13 +490,9 @@ func AuthenticateSAMLOld(appURL string, metadata *samlTypes.EntityDescriptor, se
 			return
 		}
 
-		client := &http.Client{
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				return http.ErrUseLastResponse
-			},
-		}
+		client := c.browserClient()
 
-		r, err := http.NewRequest("POST", appURL+"/api/v1/saml/callback", bytes.NewReader(b))
+		r, err := http.NewRequestWithContext(ctx, "POST", appURL+"/api/v1/saml/callback", bytes.NewReader(b))
 		if err != nil {
 			rw.WriteHeader(http.StatusBadRequest)
 			tokenChan <- SamlCallbackResult{Data: nil, Err: fmt.Errorf("error creating SAML request: %w", err)}
@@ -528,15 +538,19 @@ func AuthenticateSAMLOld(appURL string, metadata *samlTypes.EntityDescriptor, se
 		}, Err: nil}
 	})
 
-	return callExternalAuth(sp, tokenChan, printURL)
+	return callExternalAuth(ctx, sp, tokenChan, printURL)
 }
 
-func DownloadSAMLMetadata(metadataURL string) (*samlTypes.EntityDescriptor, error) {
+func (c *Client) DownloadSAMLMetadata(ctx context.Context, metadataURL string) (*samlTypes.EntityDescriptor, error) {
 	if metadataURL == "" {
 		return nil, fmt.Errorf("SAML metadata URL is empty. Please provide a valid URL to your Identity Provider's metadata")
 	}
 
-	res, err := http.Get(metadataURL)
+	req, err := http.NewRequestWithContext(ctx, "GET", metadataURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SAML metadata request for %q: %w", metadataURL, err)
+	}
+	res, err := c.httpClient().Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to download SAML metadata from %q: %w\nPlease verify:\n  1. The URL is correct\n  2. The URL is accessible from your network\n  3. The Identity Provider is online", metadataURL, err)
 	}
@@ -598,8 +612,8 @@ func truncateString(s string, maxLen int) string {
 	return s[:maxLen] + "..."
 }
 
-func getCSRFToken(appURL string, client *http.Client) (string, []*http.Cookie, error) {
-	csrfReq, err := http.NewRequest("GET", appURL+"/api/v2/csrf-token", nil)
+func getCSRFToken(ctx context.Context, appURL string, client *http.Client) (string, []*http.Cookie, error) {
+	csrfReq, err := http.NewRequestWithContext(ctx, "GET", appURL+"/api/v2/csrf-token", nil)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to create CSRF token request: %w", err)
 	}
@@ -629,8 +643,8 @@ func getCSRFToken(appURL string, client *http.Client) (string, []*http.Cookie, e
 	return csrfData.Data, csrfCookie, nil
 }
 
-func getAuthToken(appURL string, ssoCode string, csrfToken string, client *http.Client) (string, []*http.Cookie, error) {
-	authReq, err := http.NewRequest("GET", appURL+"/api/v2/login/sso-provider?code="+ssoCode, nil)
+func getAuthToken(ctx context.Context, appURL string, ssoCode string, csrfToken string, client *http.Client) (string, []*http.Cookie, error) {
+	authReq, err := http.NewRequestWithContext(ctx, "GET", appURL+"/api/v2/login/sso-provider?code="+ssoCode, nil)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to create auth token request: %w", err)
 	}
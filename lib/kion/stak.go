@@ -1,8 +1,8 @@
 package kion
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"time"
 )
 
@@ -31,7 +31,7 @@ type STAKRequest struct {
 
 // GetSTAK queries the Kion API to generate short term access keys. Must pass
 // either an account number or an account alias, one can be "".
-func GetSTAK(host string, token string, carName string, accNum string, accAlias string) (STAK, error) {
+func (c *Client) GetSTAK(ctx context.Context, carName string, accNum string, accAlias string) (STAK, error) {
 	// only account number or account alias should be provided, use the account
 	// number by default
 	if accNum != "" && accAlias != "" {
@@ -39,14 +39,15 @@ func GetSTAK(host string, token string, carName string, accNum string, accAlias
 	}
 
 	// build our query and get response
-	url := fmt.Sprintf("%v/api/v3/temporary-credentials/cloud-access-role", host)
+	path := "/api/v3/temporary-credentials/cloud-access-role"
 	query := map[string]string{}
 	data := STAKRequest{
 		AccountNumber: accNum,
 		AccountAlias:  accAlias,
 		CARName:       carName,
 	}
-	resp, _, err := runQuery("POST", url, token, query, data)
+	// minting keys is not idempotent, only retry if kion never answered
+	resp, err := c.runQueryWithRetry(ctx, retryConnection, "POST", path, query, data)
 	if err != nil {
 		return STAK{}, err
 	}
@@ -0,0 +1,282 @@
+package kion
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redacted replaces any secret value written to a trace.
+const redacted = "****"
+
+// traceBodyLimit is the number of bytes of each body written to a trace.
+const traceBodyLimit = 1024
+
+// sensitiveHeaders are always masked in traces.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Csrf-Token":        true,
+}
+
+// sensitiveFields are masked in traced JSON, form, and query values when the
+// lowercased field name contains any of them.
+var sensitiveFields = []string{"password", "token", "secret", "key", "samlresponse", "relaystate", "code"}
+
+// sensitiveText masks secrets embedded in non-JSON bodies, such as the SSO
+// code in the page Kion returns from the SAML callback.
+var sensitiveText = []*regexp.Regexp{
+	regexp.MustCompile(`(code=)[^"&'\s<]+`),
+	regexp.MustCompile(`(token:\s*')[^']+`),
+}
+
+// tracingTransport logs every request and response it carries.
+type tracingTransport struct {
+	next http.RoundTripper
+	out  io.Writer
+	mu   sync.Mutex
+}
+
+// NewTracingTransport wraps a transport so that each request and response is
+// written to out with its method, URL, status, latency, and a truncated body.
+// Credentials, session tokens, and access keys are masked. A nil next uses
+// the default transport.
+func NewTracingTransport(next http.RoundTripper, out io.Writer) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &tracingTransport{next: next, out: out}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// capture the request body without consuming it
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "DEBUG --> %s %s\n", req.Method, redactURL(req.URL))
+	writeHeaders(&b, req.Header)
+	writeBody(&b, req.URL, req.Header.Get("Content-Type"), reqBody)
+	t.write(b.String())
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	latency := time.Since(start).Round(time.Millisecond)
+
+	b.Reset()
+	if err != nil {
+		fmt.Fprintf(&b, "DEBUG <-- %s %s failed after %v: %v\n", req.Method, redactURL(req.URL), latency, err)
+		t.write(b.String())
+		return resp, err
+	}
+
+	// capture the response body and hand back an unread copy, a failed read
+	// surfaces to the caller when it reads the copy as round trippers must not
+	// return a response alongside an error
+	respBody, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	var body io.Reader = bytes.NewReader(respBody)
+	if readErr != nil {
+		body = io.MultiReader(body, &errorReader{readErr})
+	}
+	resp.Body = io.NopCloser(body)
+
+	fmt.Fprintf(&b, "DEBUG <-- %s %s %s (%v)\n", resp.Status, req.Method, redactURL(req.URL), latency)
+	writeHeaders(&b, resp.Header)
+	writeBody(&b, req.URL, resp.Header.Get("Content-Type"), respBody)
+	if readErr != nil {
+		fmt.Fprintf(&b, "    error reading body: %v\n", readErr)
+	}
+	t.write(b.String())
+
+	return resp, nil
+}
+
+// errorReader fails every read with the error it holds.
+type errorReader struct {
+	err error
+}
+
+// Read returns the held error.
+func (r *errorReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// write sends a complete trace entry to the output so concurrent requests
+// don't interleave.
+func (t *tracingTransport) write(entry string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, _ = io.WriteString(t.out, entry)
+}
+
+// writeHeaders adds the headers worth seeing to a trace entry.
+func writeHeaders(b *strings.Builder, header http.Header) {
+	for _, name := range []string{"Authorization", "Cookie", "Set-Cookie", "X-Csrf-Token", "Content-Type", "Location", "Retry-After", "X-Request-Id"} {
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		value := strings.Join(values, ", ")
+		if sensitiveHeaders[name] {
+			value = redactHeader(name, values)
+		}
+		if u, err := url.Parse(value); name == "Location" && err == nil {
+			value = redactURL(u)
+		}
+		fmt.Fprintf(b, "    %s: %s\n", name, value)
+	}
+}
+
+// writeBody adds a redacted and truncated body to a trace entry.
+func writeBody(b *strings.Builder, u *url.URL, contentType string, body []byte) {
+	if len(bytes.TrimSpace(body)) == 0 || string(body) == "null" {
+		return
+	}
+	text := redactBody(u, contentType, body)
+	if len(text) > traceBodyLimit {
+		text = text[:traceBodyLimit] + fmt.Sprintf("... (%d bytes)", len(body))
+	}
+	fmt.Fprintf(b, "    body: %s\n", text)
+}
+
+// redactHeader masks a sensitive header, keeping the auth scheme and cookie
+// names so the trace is still useful.
+func redactHeader(name string, values []string) string {
+	masked := make([]string, 0, len(values))
+	for _, value := range values {
+		switch name {
+		case "Authorization", "Proxy-Authorization":
+			if scheme, _, found := strings.Cut(value, " "); found {
+				value = scheme + " " + redacted
+			} else {
+				value = redacted
+			}
+		case "Cookie", "Set-Cookie":
+			// set-cookie carries one cookie followed by its attributes
+			parts := strings.Split(value, ";")
+			if name == "Set-Cookie" {
+				parts = parts[:1]
+			}
+			var cookies []string
+			for _, cookie := range parts {
+				cookieName, _, _ := strings.Cut(strings.TrimSpace(cookie), "=")
+				cookies = append(cookies, cookieName+"="+redacted)
+			}
+			value = strings.Join(cookies, "; ")
+		default:
+			value = redacted
+		}
+		masked = append(masked, value)
+	}
+	return strings.Join(masked, ", ")
+}
+
+// redactURL masks sensitive query parameters.
+func redactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.String()
+	}
+	masked := *u
+	masked.RawQuery = redactValues(u.Query()).Encode()
+	return masked.String()
+}
+
+// redactValues masks sensitive form or query values.
+func redactValues(values url.Values) url.Values {
+	masked := url.Values{}
+	for key, vals := range values {
+		for _, val := range vals {
+			if isSensitive(key) {
+				val = redacted
+			}
+			masked.Add(key, val)
+		}
+	}
+	return masked
+}
+
+// redactBody masks secrets in a request or response body.
+func redactBody(u *url.URL, contentType string, body []byte) string {
+	// the csrf token is the entire payload
+	if strings.HasSuffix(u.Path, "/csrf-token") {
+		return redacted
+	}
+
+	// json is walked so any sensitive field is caught
+	var parsed any
+	if json.Unmarshal(body, &parsed) == nil {
+		masked, err := json.Marshal(redactJSON(parsed))
+		if err == nil {
+			return string(masked)
+		}
+	}
+
+	// form posts, ie the saml assertion
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		if values, err := url.ParseQuery(string(body)); err == nil {
+			return redactValues(values).Encode()
+		}
+	}
+
+	// anything else is scrubbed of known patterns
+	text := string(body)
+	for _, pattern := range sensitiveText {
+		text = pattern.ReplaceAllString(text, "${1}"+redacted)
+	}
+	return text
+}
+
+// redactJSON masks sensitive fields in a decoded JSON value.
+func redactJSON(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if isSensitive(key) {
+				if _, isObject := field.(map[string]any); !isObject {
+					v[key] = redacted
+					continue
+				}
+			}
+			v[key] = redactJSON(field)
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = redactJSON(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// isSensitive reports whether a field name looks like it holds a secret.
+func isSensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, field := range sensitiveFields {
+		if strings.Contains(name, field) {
+			return true
+		}
+	}
+	return false
+}
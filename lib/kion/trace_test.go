@@ -0,0 +1,157 @@
+package kion
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestTracingTransport(t *testing.T) {
+	var trace bytes.Buffer
+	client := NewClient("https://kion.example.com", "app_abc123")
+	client.HTTPClient.Transport = NewTracingTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		resp := jsonResponse(200, `{"status":200,"data":{"access":{"token":"session-secret","expiry":"soon"},"refresh":{"token":"refresh-secret"},"user":{"id":7,"username":"jdoe"}}}`)
+		resp.Status = "200 OK"
+		resp.Header.Add("Set-Cookie", "refresh_token=cookie-secret; Path=/; HttpOnly")
+		return resp, nil
+	}), &trace)
+
+	session, err := client.Authenticate(context.Background(), 1, "jdoe", "hunter2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the client still sees the real response
+	if session.Access.Token != "session-secret" {
+		t.Errorf("response body was not passed through, got token %q", session.Access.Token)
+	}
+
+	out := trace.String()
+	for _, secret := range []string{"app_abc123", "hunter2", "session-secret", "refresh-secret", "cookie-secret"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("trace leaked %q:\n%s", secret, out)
+		}
+	}
+	for _, want := range []string{
+		"DEBUG --> POST https://kion.example.com/api/v3/token",
+		"Authorization: Bearer ****",
+		`"username":"jdoe"`,
+		"DEBUG <-- 200 OK POST https://kion.example.com/api/v3/token",
+		"Set-Cookie: refresh_token=****",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("trace missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{
+			"STAK",
+			"/api/v3/temporary-credentials/cloud-access-role",
+			"application/json",
+			`{"data":{"access_key":"AKIA","secret_access_key":"s3cr3t","session_token":"t0k3n","duration":900}}`,
+			`{"data":{"access_key":"****","duration":900,"secret_access_key":"****","session_token":"****"}}`,
+		},
+		{
+			"SAMLAssertion",
+			"/api/v1/saml/callback",
+			"application/x-www-form-urlencoded",
+			"RelayState=abc&SAMLResponse=PHNhbWw%2B",
+			"RelayState=%2A%2A%2A%2A&SAMLResponse=%2A%2A%2A%2A",
+		},
+		{
+			"SAMLCallbackPage",
+			"/api/v1/saml/callback",
+			"text/html",
+			`<a href="/login?code=sso-secret">continue</a>`,
+			`<a href="/login?code=****">continue</a>`,
+		},
+		{
+			"OldSAMLCallbackPage",
+			"/api/v1/saml/callback",
+			"text/html",
+			`window.config = { token: 'bearer-secret', other: 1 }`,
+			`window.config = { token: '****', other: 1 }`,
+		},
+		{
+			"CSRF",
+			"/api/v2/csrf-token",
+			"application/json",
+			`{"data":"csrf-secret"}`,
+			"****",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := redactBody(&url.URL{Path: test.path}, test.contentType, []byte(test.body))
+			if got != test.want {
+				t.Errorf("\ngot:\n  %s\nwanted:\n  %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTracingTransportRedactsQuery(t *testing.T) {
+	var trace bytes.Buffer
+	transport := NewTracingTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		resp := jsonResponse(302, "")
+		resp.Header.Set("Location", "https://kion.example.com/portal?code=loc-secret")
+		resp.Body = io.NopCloser(strings.NewReader(""))
+		return resp, nil
+	}), &trace)
+
+	req, _ := http.NewRequest("GET", "https://kion.example.com/api/v2/login/sso-provider?code=sso-secret", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	out := trace.String()
+	if strings.Contains(out, "sso-secret") || strings.Contains(out, "loc-secret") {
+		t.Errorf("trace leaked the sso code:\n%s", out)
+	}
+	if !strings.Contains(out, "sso-provider?code=%2A%2A%2A%2A") {
+		t.Errorf("trace missing redacted url:\n%s", out)
+	}
+}
+
+func TestTracingTransportBodyError(t *testing.T) {
+	var trace bytes.Buffer
+	readErr := errors.New("connection reset by peer")
+	transport := NewTracingTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		resp := jsonResponse(200, "")
+		resp.Body = io.NopCloser(io.MultiReader(strings.NewReader(`{"status":`), &errorReader{readErr}))
+		return resp, nil
+	}), &trace)
+
+	req, _ := http.NewRequest("GET", "https://kion.example.com/api/version", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// the caller reads what arrived and then the failure
+	body, err := io.ReadAll(resp.Body)
+	if string(body) != `{"status":` || !errors.Is(err, readErr) {
+		t.Errorf("got body %q and error %v", body, err)
+	}
+	if !strings.Contains(trace.String(), "error reading body: connection reset by peer") {
+		t.Errorf("trace missing the read error:\n%s", trace.String())
+	}
+}
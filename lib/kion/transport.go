@@ -0,0 +1,83 @@
+package kion
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// TransportOptions holds the TLS and proxy settings used to reach Kion and
+// any identity provider involved in logging in.
+type TransportOptions struct {
+	// CABundle is the path to a PEM file of certificate authorities to trust
+	// in addition to the system roots.
+	CABundle string
+
+	// ClientCert and ClientKey are paths to a PEM encoded certificate and key
+	// presented when the server requires mutual TLS.
+	ClientCert string
+	ClientKey  string
+
+	// ProxyURL routes all requests through the given proxy, when empty the
+	// standard proxy environment variables are honored.
+	ProxyURL string
+
+	// InsecureSkipVerify disables verification of server certificates.
+	InsecureSkipVerify bool
+}
+
+// NewTransport builds an HTTP transport from the provided options. It starts
+// from the default transport so connection pooling and timeouts are
+// unchanged.
+func NewTransport(opts TransportOptions) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+
+	// trust any private certificate authorities
+	if opts.CABundle != "" {
+		pem, err := os.ReadFile(opts.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in CA bundle %q", opts.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	// present a client certificate for mutual tls
+	if opts.ClientCert != "" || opts.ClientKey != "" {
+		if opts.ClientCert == "" || opts.ClientKey == "" {
+			return nil, fmt.Errorf("client_cert and client_key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	// send everything through an explicit proxy if one was given
+	if opts.ProxyURL != "" {
+		proxy, err := url.Parse(opts.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url %q: %w", opts.ProxyURL, err)
+		}
+		if proxy.Scheme == "" || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid proxy url %q: expected a scheme and host, ie http://proxy.example.com:8080", opts.ProxyURL)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	return transport, nil
+}
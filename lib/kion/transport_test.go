@@ -0,0 +1,130 @@
+package kion
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePEM writes a single PEM block to a file in dir and returns its path.
+func writePEM(t *testing.T, dir string, name string, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newClientCert creates a self-signed client certificate and key and writes
+// them to dir.
+func newClientCert(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "kion-cli-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, dir, "client.crt", "CERTIFICATE", der), writePEM(t, dir, "client.key", "PRIVATE KEY", keyDER)
+}
+
+func TestNewTransport(t *testing.T) {
+	dir := t.TempDir()
+
+	// a kion with a certificate no system trusts that asks for, but does not
+	// verify, client certificates
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			w.Header().Set("X-Client-Cert", r.TLS.PeerCertificates[0].Subject.CommonName)
+		}
+		_, _ = w.Write([]byte(`{"status":200,"data":"3.14.0"}`))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	defer server.Close()
+	caBundle := writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+	clientCert, clientKey := newClientCert(t, dir)
+
+	tests := []struct {
+		name    string
+		opts    TransportOptions
+		wantErr bool
+		wantReq bool
+	}{
+		{"Untrusted", TransportOptions{}, false, false},
+		{"CABundle", TransportOptions{CABundle: caBundle}, false, true},
+		{"Insecure", TransportOptions{InsecureSkipVerify: true}, false, true},
+		{"ClientCert", TransportOptions{CABundle: caBundle, ClientCert: clientCert, ClientKey: clientKey}, false, true},
+		{"MissingCABundle", TransportOptions{CABundle: filepath.Join(dir, "missing.pem")}, true, false},
+		{"EmptyCABundle", TransportOptions{CABundle: clientKey}, true, false},
+		{"CertWithoutKey", TransportOptions{ClientCert: clientCert}, true, false},
+		{"BadProxy", TransportOptions{ProxyURL: "proxy.example.com:8080"}, true, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transport, err := NewTransport(test.opts)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("error: got %v, wanted error %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			var gotCert string
+			client := NewClient(server.URL, "")
+			client.Retry = RetryPolicy{}
+			client.HTTPClient.Transport = transport
+			client.AfterResponse = append(client.AfterResponse, func(resp *http.Response) {
+				gotCert = resp.Header.Get("X-Client-Cert")
+			})
+
+			_, err = client.GetVersion(context.Background())
+			if (err == nil) != test.wantReq {
+				t.Errorf("request: got %v, wanted success %v", err, test.wantReq)
+			}
+			if test.opts.ClientCert != "" && gotCert != "kion-cli-test" {
+				t.Errorf("client certificate was not presented, got %q", gotCert)
+			}
+		})
+	}
+}
+
+func TestNewTransportProxy(t *testing.T) {
+	transport, err := NewTransport(TransportOptions{ProxyURL: "http://proxy.example.com:8080"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req, _ := http.NewRequest("GET", "https://kion.example.com/api/version", nil)
+	proxy, err := transport.Proxy(req)
+	if err != nil || proxy == nil || proxy.Host != "proxy.example.com:8080" {
+		t.Errorf("proxy: got %v %v, wanted proxy.example.com:8080", proxy, err)
+	}
+}
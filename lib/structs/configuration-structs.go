@@ -1,5 +1,7 @@
 package structs
 
+import "time"
+
 ////////////////////////////////////////////////////////////////////////////////
 //                                                                            //
 //  Structs                                                                   //
@@ -18,18 +20,30 @@ type Configuration struct {
 // Kion holds information about the instance of Kion with which the application
 // interfaces with as well as the credentials to do so.
 type Kion struct {
-	URL              string `yaml:"url,omitempty"`
-	APIKey           string `yaml:"api_key,omitempty"`
-	Username         string `yaml:"username,omitempty"`
-	Password         string `yaml:"password,omitempty"`
-	IDMS             string `yaml:"idms_id,omitempty"`
-	SamlMetadataFile string `yaml:"saml_metadata_file,omitempty"`
-	SamlIssuer       string `yaml:"saml_sp_issuer,omitempty"`
-	SamlPrintURL     bool   `yaml:"saml_print_url,omitempty"`
-	DisableCache     bool   `yaml:"disable_cache,omitempty"`
-	DefaultRegion    string `yaml:"default_region,omitempty"`
-	DebugMode        bool   `yaml:"debug_mode,omitempty"`
-	QuietMode        bool   `yaml:"quiet_mode,omitempty"`
+	URL                 string        `yaml:"url,omitempty"`
+	APIKey              string        `yaml:"api_key,omitempty"`
+	Username            string        `yaml:"username,omitempty"`
+	Password            string        `yaml:"password,omitempty"`
+	IDMS                string        `yaml:"idms_id,omitempty"`
+	SamlMetadataFile    string        `yaml:"saml_metadata_file,omitempty"`
+	SamlIssuer          string        `yaml:"saml_sp_issuer,omitempty"`
+	SamlPrintURL        bool          `yaml:"saml_print_url,omitempty"`
+	DisableCache        bool          `yaml:"disable_cache,omitempty"`
+	DefaultRegion       string        `yaml:"default_region,omitempty"`
+	DebugMode           bool          `yaml:"debug_mode,omitempty"`
+	QuietMode           bool          `yaml:"quiet_mode,omitempty"`
+	Timeout             time.Duration `yaml:"timeout,omitempty"`
+	RetryAttempts       int           `yaml:"retry_attempts,omitempty"`
+	RetryJitter         *float64      `yaml:"retry_jitter,omitempty"`
+	CABundle            string        `yaml:"ca_bundle,omitempty"`
+	ClientCert          string        `yaml:"client_cert,omitempty"`
+	ClientKey           string        `yaml:"client_key,omitempty"`
+	ProxyURL            string        `yaml:"proxy_url,omitempty"`
+	InsecureSkipTLS     bool          `yaml:"insecure_skip_verify,omitempty"`
+	SubshellCredentials string        `yaml:"subshell_credentials,omitempty"`
+	Shell               string        `yaml:"shell,omitempty"`
+	AWSProfileTemplate  string        `yaml:"aws_profile_template,omitempty"`
+	SaveRegion          bool          `yaml:"save_region,omitempty"`
 }
 
 // Favorite holds information about user defined favorites used to quickly
@@ -42,9 +56,9 @@ type Favorite struct {
 	Region               string `yaml:"region,omitempty" json:"account_region"`
 	Service              string `yaml:"service,omitempty"`
 	FirefoxContainerName string `yaml:"firefox_container_name,omitempty"`
-	CloudServiceProvider string `json:"cloud_service_provider"`
-	DescriptiveName      string
-	Unaliased            bool
+	CloudServiceProvider string `yaml:"cloud_service_provider,omitempty" json:"cloud_service_provider"`
+	DescriptiveName      string `yaml:"-"`
+	Unaliased            bool   `yaml:"-"`
 }
 
 // Profile holds an alternate configuration for Kion and Favorites.
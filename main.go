@@ -1,14 +1,20 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
 	"strings"
+	"syscall"
 
 	"github.com/kionsoftware/kion-cli/lib/commands"
 	"github.com/kionsoftware/kion-cli/lib/helper"
+	"github.com/kionsoftware/kion-cli/lib/kion"
 	"github.com/kionsoftware/kion-cli/lib/structs"
 
 	"github.com/fatih/color"
@@ -65,6 +71,22 @@ func main() {
 		passwordDefaultText = "*****"
 	}
 
+	// prep default text for timeout
+	timeoutDefaultText := "30s"
+	if config.Kion.Timeout > 0 {
+		timeoutDefaultText = config.Kion.Timeout.String()
+	}
+
+	// prep default text for retries
+	retryAttemptsDefaultText := fmt.Sprint(kion.DefaultRetryAttempts)
+	if config.Kion.RetryAttempts > 0 {
+		retryAttemptsDefaultText = fmt.Sprint(config.Kion.RetryAttempts)
+	}
+	retryJitter := kion.DefaultRetryJitter
+	if config.Kion.RetryJitter != nil {
+		retryJitter = *config.Kion.RetryJitter
+	}
+
 	// prep default text for api key
 	apiKeyDefaultText := ""
 	if config.Kion.APIKey != "" {
@@ -80,9 +102,58 @@ func main() {
 		}
 	}
 
+	// resolve relative tls file paths from the config file the same way
+	helper.ResolveTLSPaths(&config.Kion, configPath)
+
 	// instantiate commands, populate with config
 	cmd := commands.NewCommands(&config)
 
+	// flags for favorite commands that can change favorites in Kion
+	upstreamFlags := []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "upstream",
+			Aliases: []string{"u"},
+			Usage:   "change the favorite in Kion instead of the config file",
+		},
+		&cli.BoolFlag{
+			Name:    "yes",
+			Aliases: []string{"y"},
+			Usage:   "skip the confirmation prompt for --upstream changes",
+		},
+	}
+
+	// flags shared by the favorite add and edit commands
+	favoriteFlags := []cli.Flag{
+		&cli.StringFlag{
+			Name:    "account",
+			Aliases: []string{"acc", "a"},
+			Usage:   "account number",
+		},
+		&cli.StringFlag{
+			Name:    "car",
+			Aliases: []string{"c"},
+			Usage:   "CAR name",
+		},
+		&cli.StringFlag{
+			Name:    "access-type",
+			Aliases: []string{"t"},
+			Usage:   "access type, cli or web (default: cli)",
+		},
+		&cli.StringFlag{
+			Name:    "region",
+			Aliases: []string{"r"},
+			Usage:   "region used with the favorite",
+		},
+		&cli.StringFlag{
+			Name:  "service",
+			Usage: "AWS console service to open with web access",
+		},
+		&cli.StringFlag{
+			Name:  "firefox-container",
+			Usage: "Firefox container to open web access in",
+		},
+	}
+
 	// define app configuration
 	app := &cli.App{
 
@@ -101,6 +172,7 @@ func main() {
 			"useOldSAML":                   false,
 			"configPath":                   configPath,
 			"useFavoritesAPI":              false,
+			"kionVersion":                  "",
 		},
 
 		////////////////////
@@ -196,6 +268,94 @@ func main() {
 				Usage:       "enable quiet mode to reduce output",
 				Destination: &config.Kion.QuietMode,
 			},
+			&cli.DurationFlag{
+				Name:        "timeout",
+				Value:       config.Kion.Timeout,
+				EnvVars:     []string{"KION_TIMEOUT"},
+				Usage:       "`DURATION` to wait on each request to Kion",
+				Destination: &config.Kion.Timeout,
+				DefaultText: timeoutDefaultText,
+			},
+			&cli.IntFlag{
+				Name:        "retry-attempts",
+				Value:       config.Kion.RetryAttempts,
+				EnvVars:     []string{"KION_RETRY_ATTEMPTS"},
+				Usage:       "total `ATTEMPTS` made for requests to Kion that fail for transient reasons, 1 disables retries",
+				Destination: &config.Kion.RetryAttempts,
+				DefaultText: retryAttemptsDefaultText,
+			},
+			&cli.Float64Flag{
+				Name:    "retry-jitter",
+				Value:   retryJitter,
+				EnvVars: []string{"KION_RETRY_JITTER"},
+				Usage:   "`FRACTION` between 0 and 1 of each retry delay to randomize, 0 disables jitter",
+			},
+			&cli.StringFlag{
+				Name:        "ca-bundle",
+				Value:       config.Kion.CABundle,
+				EnvVars:     []string{"KION_CA_BUNDLE"},
+				Usage:       "PEM `FILE` of additional certificate authorities to trust",
+				Destination: &config.Kion.CABundle,
+			},
+			&cli.StringFlag{
+				Name:        "client-cert",
+				Value:       config.Kion.ClientCert,
+				EnvVars:     []string{"KION_CLIENT_CERT"},
+				Usage:       "PEM `FILE` of the client certificate to present for mutual TLS",
+				Destination: &config.Kion.ClientCert,
+			},
+			&cli.StringFlag{
+				Name:        "client-key",
+				Value:       config.Kion.ClientKey,
+				EnvVars:     []string{"KION_CLIENT_KEY"},
+				Usage:       "PEM `FILE` of the client key to use for mutual TLS",
+				Destination: &config.Kion.ClientKey,
+			},
+			&cli.StringFlag{
+				Name:        "proxy-url",
+				Value:       config.Kion.ProxyURL,
+				EnvVars:     []string{"KION_PROXY_URL"},
+				Usage:       "proxy `URL` to send all requests through",
+				Destination: &config.Kion.ProxyURL,
+			},
+			&cli.BoolFlag{
+				Name:        "insecure-skip-verify",
+				Value:       config.Kion.InsecureSkipTLS,
+				EnvVars:     []string{"KION_INSECURE_SKIP_VERIFY"},
+				Usage:       "disable TLS certificate verification, not recommended",
+				Destination: &config.Kion.InsecureSkipTLS,
+			},
+			&cli.StringFlag{
+				Name:        "subshell-credentials",
+				Value:       config.Kion.SubshellCredentials,
+				EnvVars:     []string{"KION_SUBSHELL_CREDENTIALS"},
+				Usage:       "how sub-shells receive AWS credentials, `MODE` 'static' or 'endpoint' to keep them refreshed",
+				Destination: &config.Kion.SubshellCredentials,
+				DefaultText: "static",
+			},
+			&cli.StringFlag{
+				Name:        "shell",
+				Value:       config.Kion.Shell,
+				EnvVars:     []string{"KION_SHELL"},
+				Usage:       "`SHELL` to start sub-shells in and print exports for, 'bash', 'zsh', 'fish', 'powershell', 'nu', or 'cmd'",
+				Destination: &config.Kion.Shell,
+				DefaultText: "detected",
+			},
+			&cli.StringFlag{
+				Name:        "aws-profile-template",
+				Value:       config.Kion.AWSProfileTemplate,
+				EnvVars:     []string{"KION_AWS_PROFILE_TEMPLATE"},
+				Usage:       "`TEMPLATE` to name profiles saved to the AWS credentials file, ie '{alias}-{car}', fields are account, alias, account_name, car, role, and region",
+				Destination: &config.Kion.AWSProfileTemplate,
+				DefaultText: helper.DefaultAWSProfileTemplate,
+			},
+			&cli.BoolFlag{
+				Name:        "save-region",
+				Value:       config.Kion.SaveRegion,
+				EnvVars:     []string{"KION_SAVE_REGION"},
+				Usage:       "also set the default region on saved profiles in the AWS config file",
+				Destination: &config.Kion.SaveRegion,
+			},
 		},
 
 		////////////////
@@ -247,6 +407,10 @@ func main() {
 						Name:  "credential-process",
 						Usage: "print stak json as AWS credential process",
 					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "print stak in `FORMAT`, one of " + strings.Join(helper.STAKFormatNames(), ", ") + ", implies --print",
+					},
 				},
 			},
 			{
@@ -278,6 +442,7 @@ func main() {
 				Aliases:   []string{"fav", "f"},
 				Usage:     "Access favorites via web console or a stak for CLI usage",
 				ArgsUsage: "[FAVORITE_NAME]",
+				Before:    cmd.ValidateCmdFavorite,
 				Action:    cmd.Favorites,
 				Flags: []cli.Flag{
 					&cli.BoolFlag{
@@ -299,6 +464,10 @@ func main() {
 						Name:  "credential-process",
 						Usage: "print stak json as AWS credential process",
 					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "print stak in `FORMAT`, one of " + strings.Join(helper.STAKFormatNames(), ", ") + ", implies --print",
+					},
 				},
 				BashComplete: func(cCtx *cli.Context) {
 					// complete if no args are passed
@@ -324,6 +493,66 @@ func main() {
 							},
 						},
 					},
+					{
+						Name:      "add",
+						Usage:     "add a local favorite, selecting the account and CAR if not passed",
+						ArgsUsage: "[NAME]",
+						Before:    cmd.ValidateCmdFavoriteAdd,
+						Action:    cmd.AddFavorite,
+						Flags:     slices.Concat(favoriteFlags, upstreamFlags),
+					},
+					{
+						Name:      "remove",
+						Aliases:   []string{"rm", "delete"},
+						Usage:     "remove a favorite from the config file, or from Kion with --upstream",
+						ArgsUsage: "NAME",
+						Before:    cmd.ValidateCmdFavoriteRemove,
+						Action:    cmd.RemoveFavorite,
+						Flags:     upstreamFlags,
+					},
+					{
+						Name:      "rename",
+						Usage:     "rename a favorite in the config file, or in Kion with --upstream",
+						ArgsUsage: "NAME NEW_NAME",
+						Before:    cmd.ValidateCmdFavoriteRename,
+						Action:    cmd.RenameFavorite,
+						Flags:     upstreamFlags,
+					},
+					{
+						Name:      "edit",
+						Usage:     "change the fields of a local favorite passed as flags",
+						ArgsUsage: "NAME",
+						Before:    cmd.ValidateCmdFavoriteEdit,
+						Action:    cmd.EditFavorite,
+						Flags:     favoriteFlags,
+					},
+					{
+						Name:   "export",
+						Usage:  "print favorites, local and from Kion, as a file to share",
+						Before: cmd.ValidateCmdFavoriteExport,
+						Action: cmd.ExportFavorites,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "output",
+								Aliases: []string{"o"},
+								Value:   "yaml",
+								Usage:   "output `FORMAT`, one of " + strings.Join(helper.FavoriteOutputs, ", "),
+							},
+						},
+					},
+					{
+						Name:      "import",
+						Usage:     "add favorites from a file or URL to the config file, or Kion with --upstream",
+						ArgsUsage: "FILE|URL",
+						Before:    cmd.ValidateCmdFavoriteImport,
+						Action:    cmd.ImportFavorites,
+						Flags: slices.Concat(upstreamFlags, []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "overwrite",
+								Usage: "replace existing favorites that conflict with imported ones",
+							},
+						}),
+					},
 				},
 			},
 			{
@@ -361,6 +590,238 @@ func main() {
 						Usage:       "target region",
 						Destination: &config.Kion.DefaultRegion,
 					},
+					&cli.StringFlag{
+						Name:  "favorites",
+						Usage: "run once per favorite matching the comma separated `PATTERNS`, ie 'prod-*'",
+					},
+					&cli.StringSliceFlag{
+						Name:  "accounts",
+						Usage: "run once per comma separated account `NUMBERS` using --car",
+					},
+					&cli.StringFlag{
+						Name:  "project",
+						Usage: "run once per account in the project `NAME` using --car",
+					},
+					&cli.IntFlag{
+						Name:  "parallel",
+						Value: 10,
+						Usage: "number of commands to run at once with --favorites, --accounts, or --project",
+					},
+				},
+			},
+			{
+				Name:   "serve",
+				Usage:  "Serve auto-refreshing short-term access keys on a local container credentials endpoint",
+				Before: cmd.ValidateCmdServe,
+				Action: cmd.Serve,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "favorite",
+						Aliases: []string{"fav", "f"},
+						Usage:   "favorite name",
+					},
+					&cli.StringFlag{
+						Name:    "account",
+						Aliases: []string{"acc", "a"},
+						Usage:   "target account number, must be passed with car",
+					},
+					&cli.StringFlag{
+						Name:    "alias",
+						Aliases: []string{"aka", "l"},
+						Usage:   "account alias, must be passed with car",
+					},
+					&cli.StringFlag{
+						Name:    "car",
+						Aliases: []string{"cloud-access-role", "c"},
+						Usage:   "target cloud access role, must be passed with account or alias",
+					},
+					&cli.StringFlag{
+						Name:        "region",
+						Aliases:     []string{"r"},
+						Value:       config.Kion.DefaultRegion,
+						EnvVars:     []string{"AWS_REGION", "AWS_DEFAULT_REGION"},
+						Usage:       "target region",
+						Destination: &config.Kion.DefaultRegion,
+					},
+					&cli.StringFlag{
+						Name:  "address",
+						Value: "127.0.0.1:0",
+						Usage: "loopback `HOST:PORT` to listen on, port 0 picks a free port",
+					},
+				},
+			},
+			{
+				Name:   "imds",
+				Usage:  "Emulate the EC2 instance metadata service with auto-refreshing short-term access keys",
+				Before: cmd.ValidateCmdServe,
+				Action: cmd.IMDS,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "favorite",
+						Aliases: []string{"fav", "f"},
+						Usage:   "favorite name",
+					},
+					&cli.StringFlag{
+						Name:    "account",
+						Aliases: []string{"acc", "a"},
+						Usage:   "target account number, must be passed with car",
+					},
+					&cli.StringFlag{
+						Name:    "alias",
+						Aliases: []string{"aka", "l"},
+						Usage:   "account alias, must be passed with car",
+					},
+					&cli.StringFlag{
+						Name:    "car",
+						Aliases: []string{"cloud-access-role", "c"},
+						Usage:   "target cloud access role, must be passed with account or alias",
+					},
+					&cli.StringFlag{
+						Name:        "region",
+						Aliases:     []string{"r"},
+						Value:       config.Kion.DefaultRegion,
+						EnvVars:     []string{"AWS_REGION", "AWS_DEFAULT_REGION"},
+						Usage:       "target region",
+						Destination: &config.Kion.DefaultRegion,
+					},
+					&cli.StringFlag{
+						Name:  "address",
+						Value: "127.0.0.1:0",
+						Usage: "loopback `HOST:PORT` to listen on, port 0 picks a free port",
+					},
+				},
+			},
+			{
+				Name:   "status",
+				Usage:  "Print the account, role, region, and time remaining on sub-shell credentials",
+				Before: cmd.ValidateCmdStak,
+				Action: cmd.Status,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Value:   "text",
+						Usage:   "output `FORMAT`, text, json, or prompt",
+					},
+					&cli.StringFlag{
+						Name:    "account",
+						Aliases: []string{"acc", "a"},
+						Usage:   "account number of cached keys to check, must be passed with car",
+					},
+					&cli.StringFlag{
+						Name:    "alias",
+						Aliases: []string{"aka", "l"},
+						Usage:   "account alias of cached keys to check, must be passed with car",
+					},
+					&cli.StringFlag{
+						Name:    "car",
+						Aliases: []string{"cloud-access-role", "c"},
+						Usage:   "cloud access role of cached keys to check, must be passed with account or alias",
+					},
+				},
+			},
+			{
+				Name:   "login",
+				Usage:  "Authenticate with Kion and cache the session",
+				Action: cmd.Login,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "force",
+						Aliases: []string{"f"},
+						Usage:   "discard any cached session and authenticate again",
+					},
+				},
+			},
+			{
+				Name:   "logout",
+				Usage:  "Remove cached sessions and passwords for the Kion URL",
+				Action: cmd.Logout,
+			},
+			{
+				Name:   "whoami",
+				Usage:  "Print the authenticated identity and session details",
+				Action: cmd.Whoami,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Value:   "text",
+						Usage:   "output `FORMAT`, text or json",
+					},
+				},
+			},
+			{
+				Name:  "list",
+				Usage: "List projects, accounts, and cloud access roles",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "projects",
+						Usage:  "List projects",
+						Action: cmd.ListProjects,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "output",
+								Aliases: []string{"o"},
+								Value:   "table",
+								Usage:   "output `FORMAT`, table, json, csv, or yaml",
+							},
+							&cli.StringFlag{
+								Name:  "project",
+								Usage: "only include projects with names matching the comma separated `PATTERNS`",
+							},
+						},
+					},
+					{
+						Name:   "accounts",
+						Usage:  "List accounts you have cloud access roles on",
+						Action: cmd.ListAccounts,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "output",
+								Aliases: []string{"o"},
+								Value:   "table",
+								Usage:   "output `FORMAT`, table, json, csv, or yaml",
+							},
+							&cli.StringFlag{
+								Name:  "project",
+								Usage: "only include projects with names matching the comma separated `PATTERNS`",
+							},
+							&cli.StringFlag{
+								Name:  "account-type",
+								Usage: "only include accounts of this `TYPE`, by name or id",
+							},
+						},
+					},
+					{
+						Name:    "cars",
+						Aliases: []string{"cloud-access-roles"},
+						Usage:   "List your cloud access roles",
+						Action:  cmd.ListCARS,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "output",
+								Aliases: []string{"o"},
+								Value:   "table",
+								Usage:   "output `FORMAT`, table, json, csv, or yaml",
+							},
+							&cli.StringFlag{
+								Name:  "project",
+								Usage: "only include projects with names matching the comma separated `PATTERNS`",
+							},
+							&cli.StringFlag{
+								Name:  "account-type",
+								Usage: "only include accounts of this `TYPE`, by name or id",
+							},
+							&cli.BoolFlag{
+								Name:  "web-access",
+								Usage: "only include cloud access roles with web console access",
+							},
+							&cli.BoolFlag{
+								Name:  "stak-access",
+								Usage: "only include cloud access roles that can generate short-term access keys",
+							},
+						},
+					},
 				},
 			},
 			{
@@ -377,6 +838,86 @@ func main() {
 						Usage:  "Push configured favorites to Kion",
 						Action: cmd.PushFavorites,
 					},
+					{
+						Name:   "pull-favorites",
+						Usage:  "Pull favorites from Kion into the config file",
+						Action: cmd.PullFavorites,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "to-profile",
+								Usage: "write to the favorites of profile `NAME` instead of the active profile, ie to carry them to another Kion instance",
+							},
+							&cli.BoolFlag{
+								Name:    "yes",
+								Aliases: []string{"y"},
+								Usage:   "pull every new favorite without prompting",
+							},
+							&cli.BoolFlag{
+								Name:  "overwrite",
+								Usage: "also pull favorites that conflict with local ones, replacing them",
+							},
+						},
+					},
+					{
+						Name:   "sync-aws-config",
+						Usage:  "Write AWS config profiles for favorites or cloud access roles",
+						Action: cmd.SyncAWSConfig,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "source",
+								Value: "favorites",
+								Usage: "profiles to write, 'favorites' or every accessible cloud access role with 'cars'",
+							},
+							&cli.StringFlag{
+								Name:        "name-template",
+								Usage:       "profile `TEMPLATE` using {name}, {account}, {alias}, {account_name}, {car}, and {region}",
+								DefaultText: "{name} for favorites, {account}-{car} for cars",
+							},
+							&cli.StringFlag{
+								Name:    "region",
+								Aliases: []string{"r"},
+								Usage:   "region for profiles without one, defaults to the configured default region",
+							},
+							&cli.StringFlag{
+								Name:        "aws-config",
+								Usage:       "AWS config `FILE` to write",
+								DefaultText: "$AWS_CONFIG_FILE or ~/.aws/config",
+							},
+							&cli.StringFlag{
+								Name:        "kion-path",
+								Usage:       "`PATH` of the Kion CLI used in credential_process",
+								DefaultText: "kion if it is this binary on $PATH, otherwise this binary's absolute path",
+							},
+							&cli.BoolFlag{
+								Name:  "prune",
+								Usage: "remove profiles written by the Kion CLI that are no longer synced",
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "show the changes without writing them",
+							},
+						},
+					},
+					{
+						Name:   "prune-aws-creds",
+						Usage:  "Remove expired credentials profiles saved by the Kion CLI",
+						Action: cmd.PruneAWSCreds,
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "all",
+								Usage: "remove every profile saved by the Kion CLI, expired or not",
+							},
+							&cli.StringFlag{
+								Name:        "aws-credentials",
+								Usage:       "AWS credentials `FILE` to prune",
+								DefaultText: "$AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials",
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "show the changes without writing them",
+							},
+						},
+					},
 					{
 						Name:   "validate-saml",
 						Usage:  "Validate SAML configuration and connectivity",
@@ -389,8 +930,17 @@ func main() {
 
 	// TODO: extend help output to include examples
 
+	// cancel any in-flight requests on ctrl-c or termination
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
 	// run the app
-	if err := app.Run(os.Args); err != nil {
+	err = cmd.ExplainError(app.RunContext(ctx, os.Args))
+	stop()
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			color.Red("\nCanceled")
+			os.Exit(130)
+		}
 		color.Red("\nError: %v", err)
 		os.Exit(1)
 	}